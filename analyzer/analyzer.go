@@ -0,0 +1,260 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package analyzer provides a go/analysis analyzer reporting imports that pull in libraries whose
+// license is not allowed, based on a go-licenses report.
+package analyzer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nwoodmsft/go-licenses/licenses"
+	"golang.org/x/tools/go/analysis"
+)
+
+const doc = `report imports pulling in libraries whose license is not allowed
+
+The license of each library is read from the CSV report given with -report,
+written by "go-licenses report <packages> --columns=name,license,type". An
+import is reported if the imported package, or any package it imports
+transitively, belongs to a library whose license is not allowed by
+-allowed_licenses or -disallowed_types. By default, forbidden and unknown
+license types are not allowed, like in "go-licenses check".`
+
+// Analyzer reports imports pulling in libraries whose license is not allowed.
+var Analyzer = &analysis.Analyzer{
+	Name:      "licenses",
+	Doc:       doc,
+	Run:       run,
+	FactTypes: []analysis.Fact{new(disallowedFact)},
+}
+
+var (
+	reportPath      string
+	allowedLicenses string
+	disallowedTypes string
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&reportPath, "report", "", "absolute path of the CSV report written by go-licenses report --columns=name,license,type")
+	Analyzer.Flags.StringVar(&allowedLicenses, "allowed_licenses", "", "comma separated list of allowed license names, can't be used in combination with disallowed_types")
+	Analyzer.Flags.StringVar(&disallowedTypes, "disallowed_types", "", "comma separated list of disallowed license types, can't be used in combination with allowed_licenses (default: forbidden,unknown)")
+}
+
+// disallowedFact is exported for packages that are, or import, packages of libraries whose
+// license is not allowed.
+type disallowedFact struct {
+	// Libraries describes the disallowed libraries, sorted.
+	Libraries []string
+}
+
+func (*disallowedFact) AFact() {}
+
+func (f *disallowedFact) String() string {
+	return "disallowed(" + strings.Join(f.Libraries, ", ") + ")"
+}
+
+// library is a row of the report.
+type library struct {
+	name        string
+	licenseName string
+	licenseType licenses.Type
+}
+
+// policy decides which libraries are disallowed, from the report and flags.
+type policy struct {
+	libraries       []library
+	allowedLicenses []string
+	disallowedTypes []licenses.Type
+}
+
+var (
+	loadOnce   sync.Once
+	loaded     *policy
+	loadingErr error
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	loadOnce.Do(func() {
+		loaded, loadingErr = loadPolicy()
+	})
+	if loadingErr != nil {
+		return nil, loadingErr
+	}
+
+	disallowed := make(map[string]bool)
+	if description := loaded.disallowed(pass.Pkg.Path()); description != "" {
+		disallowed[description] = true
+	}
+	for _, file := range pass.Files {
+		for _, spec := range file.Imports {
+			imported := importedPackage(pass, spec)
+			if imported == nil {
+				continue
+			}
+			var fact disallowedFact
+			if !pass.ImportPackageFact(imported, &fact) {
+				continue
+			}
+			for _, description := range fact.Libraries {
+				disallowed[description] = true
+			}
+			pass.Reportf(spec.Pos(), "import %s pulls in libraries whose license is not allowed: %s", spec.Path.Value, strings.Join(fact.Libraries, ", "))
+		}
+	}
+	if len(disallowed) > 0 {
+		fact := &disallowedFact{}
+		for description := range disallowed {
+			fact.Libraries = append(fact.Libraries, description)
+		}
+		sort.Strings(fact.Libraries)
+		pass.ExportPackageFact(fact)
+	}
+	return nil, nil
+}
+
+// importedPackage returns the package imported by spec.
+func importedPackage(pass *analysis.Pass, spec *ast.ImportSpec) *types.Package {
+	var obj types.Object
+	if spec.Name != nil {
+		obj = pass.TypesInfo.Defs[spec.Name]
+	} else {
+		obj = pass.TypesInfo.Implicits[spec]
+	}
+	if pkgName, ok := obj.(*types.PkgName); ok {
+		return pkgName.Imported()
+	}
+	// Blank imports may not be recorded, fall back to the import path.
+	path, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return nil
+	}
+	for _, imported := range pass.Pkg.Imports() {
+		if imported.Path() == path {
+			return imported
+		}
+	}
+	return nil
+}
+
+func loadPolicy() (*policy, error) {
+	if reportPath == "" {
+		return nil, fmt.Errorf("-licenses.report is required")
+	}
+	p := &policy{allowedLicenses: splitList(allowedLicenses)}
+	for _, name := range splitList(disallowedTypes) {
+		t, err := parseType(name)
+		if err != nil {
+			return nil, err
+		}
+		p.disallowedTypes = append(p.disallowedTypes, t)
+	}
+	if len(p.allowedLicenses) > 0 && len(p.disallowedTypes) > 0 {
+		return nil, fmt.Errorf("-licenses.allowed_licenses and -licenses.disallowed_types can't be used at the same time")
+	}
+	if len(p.allowedLicenses) == 0 && len(p.disallowedTypes) == 0 {
+		p.disallowedTypes = []licenses.Type{licenses.Forbidden, licenses.Unknown}
+	}
+	f, err := os.Open(reportPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p.libraries, err = readReport(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", reportPath, err)
+	}
+	return p, nil
+}
+
+// readReport reads a CSV report with the columns name, license and type.
+func readReport(r io.Reader) ([]library, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	var libs []library
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w, expected the columns name,license,type", err)
+		}
+		t, err := parseType(record[2])
+		if err != nil {
+			return nil, err
+		}
+		libs = append(libs, library{name: record[0], licenseName: record[1], licenseType: t})
+	}
+	// Longest names first, so that the first match of a package is its library.
+	sort.SliceStable(libs, func(i, j int) bool {
+		return len(libs[i].name) > len(libs[j].name)
+	})
+	return libs, nil
+}
+
+// disallowed returns a description of the library of the package with the given import path if
+// its license is not allowed, or "" otherwise. Packages missing from the report, e.g. of the
+// standard library or the reported modules themselves, are allowed.
+func (p *policy) disallowed(pkgPath string) string {
+	for _, lib := range p.libraries {
+		if pkgPath != lib.name && !strings.HasPrefix(pkgPath, lib.name+"/") {
+			continue
+		}
+		if len(p.allowedLicenses) > 0 {
+			for _, allowed := range p.allowedLicenses {
+				if allowed == lib.licenseName {
+					return ""
+				}
+			}
+			return fmt.Sprintf("%s (license %s)", lib.name, lib.licenseName)
+		}
+		for _, t := range p.disallowedTypes {
+			if t == lib.licenseType {
+				return fmt.Sprintf("%s (license %s of type %s)", lib.name, lib.licenseName, lib.licenseType)
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseType parses a license type as printed by go-licenses, ignoring case.
+func parseType(s string) (licenses.Type, error) {
+	for _, t := range []licenses.Type{licenses.Forbidden, licenses.Notice, licenses.Permissive, licenses.Reciprocal, licenses.Restricted, licenses.Unencumbered, licenses.Unknown} {
+		if strings.EqualFold(strings.TrimSpace(s), t.String()) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown license type %q, allowed types: forbidden, notice, permissive, reciprocal, restricted, unencumbered, unknown", s)
+}
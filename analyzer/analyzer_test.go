@@ -0,0 +1,64 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nwoodmsft/go-licenses/licenses"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "licenses.csv")
+	content := "bad,WTFPL,FORBIDDEN\nfine,MIT,notice\nwrapper,Apache-2.0,notice\n"
+	if err := os.WriteFile(report, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Analyzer.Flags.Set("report", report); err != nil {
+		t.Fatal(err)
+	}
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "app", "wrapper", "bad", "fine")
+}
+
+func TestPolicyDisallowed(t *testing.T) {
+	libs, err := readReport(strings.NewReader("example.com/lib,GPL-3.0,restricted\nexample.com/lib/sub,MIT,notice\nexample.com/other,Unknown,unknown\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byType := &policy{libraries: libs, disallowedTypes: []licenses.Type{licenses.Restricted}}
+	byName := &policy{libraries: libs, allowedLicenses: []string{"GPL-3.0", "MIT"}}
+	for _, test := range []struct {
+		pkgPath    string
+		wantByType string
+		wantByName string
+	}{
+		{pkgPath: "example.com/lib/pkg", wantByType: "example.com/lib (license GPL-3.0 of type restricted)"},
+		{pkgPath: "example.com/lib/sub/pkg"},
+		{pkgPath: "example.com/library"},
+		{pkgPath: "example.com/other", wantByName: "example.com/other (license Unknown)"},
+		{pkgPath: "fmt"},
+	} {
+		if got := byType.disallowed(test.pkgPath); got != test.wantByType {
+			t.Errorf("disallowed_types: disallowed(%q) = %q, want %q", test.pkgPath, got, test.wantByType)
+		}
+		if got := byName.disallowed(test.pkgPath); got != test.wantByName {
+			t.Errorf("allowed_licenses: disallowed(%q) = %q, want %q", test.pkgPath, got, test.wantByName)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package app // want package:`disallowed\(bad \(license WTFPL of type FORBIDDEN\)\)`
+
+import (
+	_ "bad" // want `import "bad" pulls in libraries whose license is not allowed`
+	"fine"
+	w "wrapper" // want `import "wrapper" pulls in libraries whose license is not allowed: bad \(license WTFPL of type FORBIDDEN\)`
+)
+
+func App() {
+	fine.Fine()
+	w.Wrap()
+}
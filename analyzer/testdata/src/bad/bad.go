@@ -0,0 +1,3 @@
+package bad // want package:`disallowed\(bad \(license WTFPL of type FORBIDDEN\)\)`
+
+func Bad() {}
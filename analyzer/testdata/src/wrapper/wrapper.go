@@ -0,0 +1,5 @@
+package wrapper // want package:`disallowed\(bad \(license WTFPL of type FORBIDDEN\)\)`
+
+import "bad" // want `import "bad" pulls in libraries whose license is not allowed: bad \(license WTFPL of type FORBIDDEN\)`
+
+func Wrap() { bad.Bad() }
@@ -0,0 +1,154 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Supported archive formats, inferred from the output file name.
+const (
+	archiveZip   = "zip"
+	archiveTarGz = "tar.gz"
+)
+
+// manifestFile is the name of the manifest describing saved files.
+const manifestFile = "manifest.json"
+
+// manifest describes which library each saved file belongs to.
+type manifest struct {
+	Files []manifestEntry `json:"files"`
+}
+
+type manifestEntry struct {
+	// Path of the file relative to the manifest, separated by forward slashes.
+	Path    string `json:"path"`
+	Library string `json:"library"`
+	Module  string `json:"module,omitempty"`
+	Version string `json:"version,omitempty"`
+	License string `json:"license,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// write writes the manifest to manifestFile in dir.
+func (m *manifest) write(dir string) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, manifestFile), append(content, '\n'), 0644)
+}
+
+// archiveFormat returns the archive format indicated by the extension of path,
+// or "" if path is not an archive.
+func archiveFormat(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".zip"):
+		return archiveZip
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return archiveTarGz
+	default:
+		return ""
+	}
+}
+
+// writeArchive writes all regular files in dir to w as an archive of the given format.
+// File names in the archive are relative to dir.
+func writeArchive(w io.Writer, format, dir string) error {
+	switch format {
+	case archiveZip:
+		zw := zip.NewWriter(w)
+		err := walkFiles(dir, func(name string, info fs.FileInfo, r io.Reader) error {
+			header, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			header.Name = name
+			header.Method = zip.Deflate
+			fw, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(fw, r)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return zw.Close()
+	case archiveTarGz:
+		gw := gzip.NewWriter(w)
+		tw := tar.NewWriter(gw)
+		err := walkFiles(dir, func(name string, info fs.FileInfo, r io.Reader) error {
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = name
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, r)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		return gw.Close()
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+}
+
+// walkFiles calls fn for every regular file in dir, with its slash separated path relative to dir.
+func walkFiles(dir string, fn func(name string, info fs.FileInfo, r io.Reader) error) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return fn(filepath.ToSlash(name), info, f)
+	})
+}
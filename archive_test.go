@@ -0,0 +1,112 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteArchive(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"github.com/example/lib/LICENSE": "license",
+		"github.com/example/lib/NOTICE":  "notice",
+		"manifest.json":                  "{}",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, format := range []string{archiveZip, archiveTarGz} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeArchive(&buf, format, dir); err != nil {
+				t.Fatalf("writeArchive() = %v, want nil", err)
+			}
+			got := make(map[string]string)
+			switch format {
+			case archiveZip:
+				zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, f := range zr.File {
+					r, err := f.Open()
+					if err != nil {
+						t.Fatal(err)
+					}
+					content, err := io.ReadAll(r)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got[f.Name] = string(content)
+				}
+			case archiveTarGz:
+				gr, err := gzip.NewReader(&buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tr := tar.NewReader(gr)
+				for {
+					header, err := tr.Next()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					content, err := io.ReadAll(tr)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got[header.Name] = string(content)
+				}
+			}
+			if diff := cmp.Diff(files, got); diff != "" {
+				t.Errorf("archive content diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestArchiveFormat(t *testing.T) {
+	for path, want := range map[string]string{
+		"licenses":        "",
+		"licenses.csv.gz": "",
+		"licenses.zip":    archiveZip,
+		"licenses.tar.gz": archiveTarGz,
+		"licenses.tgz":    archiveTarGz,
+	} {
+		if got := archiveFormat(path); got != want {
+			t.Errorf("archiveFormat(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/nwoodmsft/go-licenses/licenses"
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+)
+
+var (
+	bazelHelp = "Reports the licenses of the go modules declared with go_repository rules in a Bazel workspace."
+	bazelCmd  = &cobra.Command{
+		Use:   "bazel [workspace]",
+		Short: bazelHelp,
+		Long: bazelHelp + `
+
+The go_repository rules, as written by Gazelle's update-repos command, are read
+from the WORKSPACE, WORKSPACE.bazel and deps.bzl files of the workspace
+(default: the current directory), from the files given with --deps_file, or
+from "bazel query" with --query. Licenses are identified in the external
+repositories Bazel fetched to its output base. Repositories that haven't been
+fetched are downloaded with "go mod download" instead, if they have a version.
+The report has one entry per repository, in the format chosen with --format.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: bazelMain,
+	}
+
+	bazelDepsFiles   []string
+	bazelOutputBase  string
+	bazelQuery       bool
+	bazelOutputPath  string
+	bazelCompression string
+	bazelFormat      string
+)
+
+// bazelDefaultDepsFiles are the files read for go_repository rules if --deps_file isn't set.
+var bazelDefaultDepsFiles = []string{"WORKSPACE", "WORKSPACE.bazel", "deps.bzl"}
+
+func init() {
+	bazelCmd.Flags().StringSliceVar(&bazelDepsFiles, "deps_file", nil, "Starlark file declaring go_repository rules, relative to the workspace. Can be specified multiple times. (default: WORKSPACE, WORKSPACE.bazel and deps.bzl, if they exist)")
+	bazelCmd.Flags().StringVar(&bazelOutputBase, "output_base", "", "Bazel output base containing the fetched external repositories. (default: the output of \"bazel info output_base\" in the workspace)")
+	bazelCmd.Flags().BoolVar(&bazelQuery, "query", false, "Read the go_repository rules from \"bazel query\" instead of Starlark files, including rules declared by macros. Can't be used in combination with --deps_file.")
+	bazelCmd.Flags().StringVar(&bazelOutputPath, "output", "", "File to write the report to. The file is only replaced once the report is complete. (default: stdout)")
+	bazelCmd.Flags().StringVar(&bazelCompression, "compress", "", "Compress the report, one of: none, gzip, zstd. (default: inferred from the --output file extension .gz or .zst)")
+	bazelCmd.Flags().StringVar(&bazelFormat, "format", "csv", "Report format, one of: "+strings.Join(moduleReportFormatNames(), ", ")+", or the name of a format plugin.")
+
+	rootCmd.AddCommand(bazelCmd)
+}
+
+// goRepository is a go_repository rule.
+type goRepository struct {
+	Name       string
+	Importpath string
+	Version    string
+	Commit     string
+	Replace    string
+}
+
+func bazelMain(_ *cobra.Command, args []string) error {
+	if bazelQuery && len(bazelDepsFiles) > 0 {
+		return fmt.Errorf("--query and --deps_file can't be used at the same time")
+	}
+	writeReport, err := moduleReportWriter(bazelFormat)
+	if err != nil {
+		return err
+	}
+	workspace := "."
+	if len(args) > 0 {
+		workspace = args[0]
+	}
+
+	repos, err := bazelRepositories(workspace)
+	if err != nil {
+		return err
+	}
+	outputBase := bazelOutputBase
+	if outputBase == "" {
+		out, err := runBazel(workspace, "info", "output_base")
+		if err != nil {
+			return fmt.Errorf("finding the Bazel output base, set --output_base if bazel isn't installed: %w", err)
+		}
+		outputBase = strings.TrimSpace(out)
+	}
+
+	var modules []licenses.Module
+	var missing []moduleVersion
+	for _, repo := range repos {
+		if isIgnored(repo.Importpath) {
+			continue
+		}
+		m := licenses.Module{Path: repo.Importpath, Version: repo.Version, Dir: filepath.Join(outputBase, "external", repo.Name)}
+		if repo.Replace != "" {
+			m.Path = repo.Replace
+		}
+		if m.Version == "" {
+			m.Version = repo.Commit
+		}
+		if _, err := os.Stat(m.Dir); err != nil {
+			klog.V(2).Infof("External repository %s hasn't been fetched: %v", repo.Name, err)
+			m.Dir = ""
+			if repo.Version != "" {
+				missing = append(missing, moduleVersion{Path: m.Path, Version: repo.Version})
+			} else {
+				klog.Errorf("External repository %s hasn't been fetched and has no version to download, run bazel fetch first", repo.Name)
+			}
+		}
+		modules = append(modules, m)
+	}
+	dirs, err := downloadModules(context.Background(), missing)
+	if err != nil {
+		return err
+	}
+	for i, m := range modules {
+		if m.Dir == "" {
+			modules[i].Dir = dirs[moduleVersion{Path: m.Path, Version: m.Version}]
+		}
+	}
+	return writeModuleReport(writeReport, modules, bazelOutputPath, bazelCompression)
+}
+
+// bazelRepositories returns the go_repository rules of the workspace, sorted by import path.
+func bazelRepositories(workspace string) ([]goRepository, error) {
+	var repos []goRepository
+	if bazelQuery {
+		out, err := runBazel(workspace, "query", "--output=build", "kind(go_repository, //external:*)")
+		if err != nil {
+			return nil, err
+		}
+		repos = parseGoRepositories(out)
+	} else {
+		files := bazelDepsFiles
+		mustExist := true
+		if len(files) == 0 {
+			files = bazelDefaultDepsFiles
+			mustExist = false
+		}
+		for _, file := range files {
+			content, err := os.ReadFile(filepath.Join(workspace, file))
+			if os.IsNotExist(err) && !mustExist {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			repos = append(repos, parseGoRepositories(string(content))...)
+		}
+	}
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("no go_repository rules found in workspace %s", workspace)
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].Importpath < repos[j].Importpath
+	})
+	return repos, nil
+}
+
+// runBazel runs bazel in the workspace and returns its stdout.
+func runBazel(workspace string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("bazel", args...)
+	cmd.Dir = workspace
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("bazel %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// starlarkToken is a token of Starlark source: an identifier, a string literal with its value,
+// or any other character.
+type starlarkToken struct {
+	ident  string
+	str    *string
+	symbol rune
+}
+
+// parseGoRepositories returns the go_repository rules called in Starlark source, e.g. a WORKSPACE
+// or deps.bzl file, or the output of "bazel query --output=build". Only attributes set to string
+// literals are read, and rules without a name or importpath are skipped.
+func parseGoRepositories(src string) []goRepository {
+	tokens := starlarkTokens(src)
+	var repos []goRepository
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i].ident != "go_repository" || tokens[i+1].symbol != '(' || (i > 0 && tokens[i-1].ident == "def") {
+			continue
+		}
+		attrs := make(map[string]string)
+		depth := 0
+		for i++; i < len(tokens); i++ {
+			switch tokens[i].symbol {
+			case '(', '[', '{':
+				depth++
+			case ')', ']', '}':
+				depth--
+			}
+			if depth == 0 {
+				break
+			}
+			// An attribute is an identifier, "=" and a string literal at the top level of the call,
+			// followed by "," or ")".
+			if depth == 1 && i+3 < len(tokens) && tokens[i].ident != "" && tokens[i+1].symbol == '=' && tokens[i+2].str != nil &&
+				(tokens[i+3].symbol == ',' || tokens[i+3].symbol == ')') {
+				attrs[tokens[i].ident] = *tokens[i+2].str
+			}
+		}
+		repo := goRepository{
+			Name:       attrs["name"],
+			Importpath: attrs["importpath"],
+			Version:    attrs["version"],
+			Commit:     attrs["commit"],
+			Replace:    attrs["replace"],
+		}
+		if repo.Name == "" || repo.Importpath == "" {
+			continue
+		}
+		repos = append(repos, repo)
+	}
+	return repos
+}
+
+// starlarkTokens splits Starlark source into tokens, skipping whitespace and comments.
+func starlarkTokens(src string) []starlarkToken {
+	var tokens []starlarkToken
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '"' || r == '\'':
+			quote := []rune{r}
+			if hasRunes(runes[i:], []rune{r, r, r}) {
+				quote = []rune{r, r, r}
+			}
+			i += len(quote)
+			var value strings.Builder
+			for i < len(runes) && !hasRunes(runes[i:], quote) {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						value.WriteRune('\n')
+					case 't':
+						value.WriteRune('\t')
+					default:
+						value.WriteRune(runes[i])
+					}
+				} else {
+					value.WriteRune(runes[i])
+				}
+				i++
+			}
+			i += len(quote)
+			s := value.String()
+			tokens = append(tokens, starlarkToken{str: &s})
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, starlarkToken{ident: string(runes[start:i])})
+		default:
+			tokens = append(tokens, starlarkToken{symbol: r})
+			i++
+		}
+	}
+	return tokens
+}
+
+// hasRunes reports whether runes starts with prefix.
+func hasRunes(runes, prefix []rune) bool {
+	if len(runes) < len(prefix) {
+		return false
+	}
+	for i, r := range prefix {
+		if runes[i] != r {
+			return false
+		}
+	}
+	return true
+}
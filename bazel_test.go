@@ -0,0 +1,107 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testDepsBzl = `"""Go dependencies, managed by gazelle update-repos."""
+
+load("@bazel_gazelle//:deps.bzl", "go_repository")
+
+def go_repository(name, **kwargs):
+    # A wrapper, not a rule call.
+    pass
+
+def go_dependencies():
+    go_repository(
+        name = "com_github_google_uuid",
+        build_extra_args = ["-exclude=testdata"],  # not a string
+        importpath = "github.com/google/uuid",
+        sum = "h1:t6JiXgmwXMjEs8VusXIJk2BXHsn+wx8BZdTaoZ5fu7I=",
+        version = "v1.3.0",
+    )
+    go_repository(
+        name = 'org_golang_x_sys',
+        importpath = 'golang.org/x/sys',
+        replace = "github.com/golang/sys",
+        version = "v0.1.0",
+    )
+    go_repository(
+        name = "com_github_example_old",
+        commit = "0123456789abcdef",
+        importpath = "github.com/example/old",
+        version = PINNED_VERSION,
+    )
+    go_repository(
+        # Skipped, without an import path.
+        name = "broken",
+    )
+`
+
+func TestParseGoRepositories(t *testing.T) {
+	want := []goRepository{
+		{Name: "com_github_google_uuid", Importpath: "github.com/google/uuid", Version: "v1.3.0"},
+		{Name: "org_golang_x_sys", Importpath: "golang.org/x/sys", Version: "v0.1.0", Replace: "github.com/golang/sys"},
+		{Name: "com_github_example_old", Importpath: "github.com/example/old", Commit: "0123456789abcdef"},
+	}
+	if diff := cmp.Diff(want, parseGoRepositories(testDepsBzl)); diff != "" {
+		t.Errorf("parseGoRepositories(): diff (-want +got)\n%s", diff)
+	}
+}
+
+func TestBazelMain(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workspace, "deps.bzl"), []byte(testDepsBzl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outputBase := t.TempDir()
+	license, err := os.ReadFile(filepath.Join("licenses", "testdata", "MIT", "LICENSE.MIT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"com_github_google_uuid", "com_github_example_old"} {
+		dir := filepath.Join(outputBase, "external", name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "LICENSE"), license, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	output := filepath.Join(t.TempDir(), "report.csv")
+	oldOutputBase, oldOutputPath, oldIgnore := bazelOutputBase, bazelOutputPath, ignore
+	bazelOutputBase, bazelOutputPath, ignore = outputBase, output, []string{"golang.org/x/sys"}
+	t.Cleanup(func() { bazelOutputBase, bazelOutputPath, ignore = oldOutputBase, oldOutputPath, oldIgnore })
+
+	if err := bazelMain(nil, []string{workspace}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `github.com/example/old,https://github.com/example/old/blob/0123456789abcdef/LICENSE,MIT
+github.com/google/uuid,https://github.com/google/uuid/blob/v1.3.0/LICENSE,MIT
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("bazel report: diff (-want +got)\n%s", diff)
+	}
+}
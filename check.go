@@ -18,13 +18,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 
 	"github.com/nwoodmsft/go-licenses/licenses"
 	"github.com/spf13/cobra"
+	"golang.org/x/mod/modfile"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
+	"k8s.io/klog/v2"
 )
 
 var (
@@ -39,77 +42,224 @@ var (
 
 	allowedLicenses []string
 	disallowedTypes []string
+	checkFormat     string
+)
+
+// Supported values of --format.
+const (
+	// checkFormatText prints violations as plain text to stderr.
+	checkFormatText = "text"
+	// checkFormatGitHub prints violations as GitHub Actions workflow commands to stdout, so that
+	// they are shown as annotations on the go.mod file of the pull request.
+	checkFormatGitHub = "github"
 )
 
 func init() {
 	checkCmd.Flags().StringSliceVar(&allowedLicenses, "allowed_licenses", []string{}, "list of allowed license names, can't be used in combination with disallowed_types")
 	checkCmd.Flags().StringSliceVar(&disallowedTypes, "disallowed_types", []string{}, "list of disallowed license types, can't be used in combination with allowed_licenses (default: forbidden, unknown)")
+	checkCmd.Flags().StringVar(&checkFormat, "format", checkFormatText, "Output format, one of: text, github. github prints ::error and ::warning workflow commands, which GitHub Actions shows as annotations on go.mod.")
 
 	rootCmd.AddCommand(checkCmd)
 }
 
 func checkMain(_ *cobra.Command, args []string) error {
-	var disallowedLicenseTypes []licenses.Type
-
-	allowedLicenseNames := getAllowedLicenseNames()
-	disallowedLicenseTypes = getDisallowedLicenseTypes()
-
-	hasLicenseNames := len(allowedLicenseNames) > 0
-	hasLicenseType := len(disallowedLicenseTypes) > 0
-
-	if hasLicenseNames && hasLicenseType {
-		return errors.New("allowed_licenses && disallowed_types can't be used at the same time")
+	if checkFormat != checkFormatText && checkFormat != checkFormatGitHub {
+		return fmt.Errorf("unknown format %q, supported: %s, %s", checkFormat, checkFormatText, checkFormatGitHub)
 	}
-
-	if !hasLicenseNames && !hasLicenseType {
-		// fallback to original behaviour to avoid breaking changes
-		disallowedLicenseTypes = []licenses.Type{licenses.Forbidden, licenses.Unknown}
-		hasLicenseType = true
+	if webhookFormat != webhookFormatJSON && webhookFormat != webhookFormatSlack {
+		return fmt.Errorf("unknown webhook format %q, supported: %s, %s", webhookFormat, webhookFormatJSON, webhookFormatSlack)
+	}
+	if jiraURL != "" && jiraProject == "" {
+		return errors.New("--jira_project is required with --jira_url")
+	}
+	policy, err := newLicensePolicy(allowedLicenses, disallowedTypes)
+	if err != nil {
+		return err
 	}
 
-	classifier, err := licenses.NewClassifier(confidenceThreshold)
+	classifier, identifier, err := newClassifiers()
 	if err != nil {
 		return err
 	}
 
-	libs, err := licenses.Libraries(context.Background(), classifier, ignore, args...)
+	libs, err := licenses.Libraries(context.Background(), classifier, ignore, nil, args...)
 	if err != nil {
 		return err
 	}
 
-	// indicate that a forbidden license was found
-	found := false
+	var violations []violation
+	var annotations []string
+	goMod := goModLines("go.mod")
 
 	for _, lib := range libs {
-		licenseName, licenseType, err := classifier.Identify(lib.LicensePath)
+		licenseName, licenseType, err := identifier.Identify(lib.LicensePath)
 		if err != nil {
 			return err
 		}
+		// Vendored libraries are checked as the module they were vendored from.
+		module, version := lib.ModulePath(), lib.Version()
+		if m := lib.VendoredFrom(); m != nil {
+			module, version = m.Path, m.Version
+		}
+		libViolations := policy.violations(lib.Name(), module, version, licenseName, licenseType)
+
+		violations = append(violations, libViolations...)
+		if checkFormat == checkFormatText {
+			for _, v := range libViolations {
+				fmt.Fprintln(os.Stderr, v.Message)
+			}
+			continue
+		}
+		line := goMod[module]
+		for _, v := range libViolations {
+			annotations = append(annotations, githubAnnotation("error", "go.mod", line, "License not allowed", v.Message))
+		}
+		if len(libViolations) == 0 && licenseType == licenses.Unknown {
+			annotations = append(annotations, githubAnnotation("warning", "go.mod", line, "Unknown license", fmt.Sprintf("Unknown license found for library %v", lib)))
+		}
+	}
+
+	for _, a := range annotations {
+		fmt.Println(a)
+	}
 
-		if hasLicenseNames && !isAllowedLicenseName(licenseName, allowedLicenseNames) {
-			fmt.Fprintf(os.Stderr, "Not allowed license %s found for library %v\n", licenseName, lib)
-			found = true
+	if webhookURL != "" {
+		// A failed notification is logged, the violations still fail the check.
+		if err := notifyViolations(context.Background(), http.DefaultClient, violations); err != nil {
+			klog.Errorf("Notifying webhook: %v", err)
 		}
+	}
 
-		if hasLicenseType && isDisallowedLicenseType(licenseType, disallowedLicenseTypes) {
-			fmt.Fprintf(
-				os.Stderr,
-				"%s license type %s found for library %v\n",
-				cases.Title(language.English).String(licenseType.String()),
-				licenseName,
-				lib)
-			found = true
+	if jiraURL != "" {
+		jira := &jiraClient{client: http.DefaultClient, baseURL: jiraURL, user: jiraUser, token: jiraToken}
+		// Issues are scoped to the main module, or the packages checked outside of a module.
+		scope := strings.Join(args, " ")
+		if content, err := os.ReadFile("go.mod"); err == nil {
+			scope = modfile.ModulePath(content)
+		}
+		if err := syncJiraIssues(context.Background(), jira, scope, violations); err != nil {
+			klog.Errorf("Synchronizing Jira issues: %v", err)
 		}
 	}
 
-	if found {
+	if len(violations) > 0 {
 		os.Exit(1)
 	}
 
 	return nil
 }
 
-func getDisallowedLicenseTypes() []licenses.Type {
+// violation is a library whose license is not allowed by a check rule.
+type violation struct {
+	Library string `json:"library"`
+	Module  string `json:"module"`
+	Version string `json:"version"`
+	License string `json:"license"`
+	// Rule is the flag and value that disallows the license, e.g. disallowed_types=forbidden.
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// licensePolicy is the set of licenses check disallows.
+type licensePolicy struct {
+	allowedNames    []string
+	disallowedTypes []licenses.Type
+}
+
+// newLicensePolicy returns the policy of the --allowed_licenses and --disallowed_types flag values.
+// If neither is set, forbidden and unknown licenses are disallowed.
+func newLicensePolicy(allowedLicenses, disallowedTypes []string) (licensePolicy, error) {
+	p := licensePolicy{
+		allowedNames:    getAllowedLicenseNames(allowedLicenses),
+		disallowedTypes: getDisallowedLicenseTypes(disallowedTypes),
+	}
+	if len(p.allowedNames) > 0 && len(p.disallowedTypes) > 0 {
+		return licensePolicy{}, errors.New("allowed_licenses && disallowed_types can't be used at the same time")
+	}
+	if len(p.allowedNames) == 0 && len(p.disallowedTypes) == 0 {
+		// fallback to original behaviour to avoid breaking changes
+		p.disallowedTypes = []licenses.Type{licenses.Forbidden, licenses.Unknown}
+	}
+	return p, nil
+}
+
+// violations returns the rules of p that disallow the license of a library.
+func (p licensePolicy) violations(library, module, version, licenseName string, licenseType licenses.Type) []violation {
+	var violations []violation
+	if len(p.allowedNames) > 0 && !isAllowedLicenseName(licenseName, p.allowedNames) {
+		violations = append(violations, violation{
+			Library: library,
+			Module:  module,
+			Version: version,
+			License: licenseName,
+			Rule:    "allowed_licenses",
+			Message: fmt.Sprintf("Not allowed license %s found for library %s", licenseName, library),
+		})
+	}
+
+	if isDisallowedLicenseType(licenseType, p.disallowedTypes) {
+		violations = append(violations, violation{
+			Library: library,
+			Module:  module,
+			Version: version,
+			License: licenseName,
+			Rule:    "disallowed_types=" + licenseType.String(),
+			Message: fmt.Sprintf(
+				"%s license type %s found for library %s",
+				cases.Title(language.English).String(licenseType.String()),
+				licenseName,
+				library),
+		})
+	}
+	return violations
+}
+
+// goModLines returns the lines of the module and require directives in the go.mod file at path,
+// keyed by module path. It returns an empty map if the file can't be read.
+func goModLines(path string) map[string]int {
+	lines := make(map[string]int)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return lines
+	}
+	f, err := modfile.ParseLax(path, content, nil)
+	if err != nil {
+		klog.Warningf("Parsing %s: %v", path, err)
+		return lines
+	}
+	if f.Module != nil && f.Module.Syntax != nil {
+		lines[f.Module.Mod.Path] = f.Module.Syntax.Start.Line
+	}
+	for _, r := range f.Require {
+		if r.Syntax != nil {
+			lines[r.Mod.Path] = r.Syntax.Start.Line
+		}
+	}
+	return lines
+}
+
+// githubAnnotation returns a GitHub Actions workflow command creating an annotation of the given
+// level (error, warning or notice) on file. The line is omitted if it is 0.
+func githubAnnotation(level, file string, line int, title, message string) string {
+	props := "file=" + escapeGitHubProperty(file)
+	if line > 0 {
+		props += fmt.Sprintf(",line=%d", line)
+	}
+	props += ",title=" + escapeGitHubProperty(title)
+	return fmt.Sprintf("::%s %s::%s", level, props, escapeGitHubData(message))
+}
+
+// escapeGitHubData escapes the message of a workflow command.
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeGitHubProperty escapes a property value of a workflow command.
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+func getDisallowedLicenseTypes(disallowedTypes []string) []licenses.Type {
 	if len(disallowedTypes) == 0 {
 		return []licenses.Type{}
 	}
@@ -154,7 +304,7 @@ func isDisallowedLicenseType(licenseType licenses.Type, excludedLicenseTypes []l
 	return false
 }
 
-func getAllowedLicenseNames() []string {
+func getAllowedLicenseNames(allowedLicenses []string) []string {
 	if len(allowedLicenses) == 0 {
 		return []string{}
 	}
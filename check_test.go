@@ -0,0 +1,138 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGitHubAnnotation(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		level   string
+		line    int
+		title   string
+		message string
+		want    string
+	}{
+		{
+			desc:    "Error on line",
+			level:   "error",
+			line:    7,
+			title:   "License not allowed",
+			message: "Forbidden license type WTFPL found for library github.com/logrusorgru/aurora",
+			want:    "::error file=go.mod,line=7,title=License not allowed::Forbidden license type WTFPL found for library github.com/logrusorgru/aurora",
+		},
+		{
+			desc:    "Warning without line",
+			level:   "warning",
+			title:   "Unknown license",
+			message: "Unknown license found for library example.com/foo",
+			want:    "::warning file=go.mod,title=Unknown license::Unknown license found for library example.com/foo",
+		},
+		{
+			desc:    "Escapes special characters",
+			level:   "error",
+			title:   "a, b: c",
+			message: "100%\nsure",
+			want:    "::error file=go.mod,title=a%2C b%3A c::100%25%0Asure",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := githubAnnotation(test.level, "go.mod", test.line, test.title, test.message); got != test.want {
+				t.Errorf("githubAnnotation() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGoModLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go.mod")
+	content := `module example.com/app
+
+go 1.17
+
+require github.com/google/go-cmp v0.5.9
+
+require (
+	github.com/spf13/cobra v1.6.0
+	golang.org/x/mod v0.6.0 // indirect
+)
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{
+		"example.com/app":          1,
+		"github.com/google/go-cmp": 5,
+		"github.com/spf13/cobra":   8,
+		"golang.org/x/mod":         9,
+	}
+	if diff := cmp.Diff(want, goModLines(path)); diff != "" {
+		t.Errorf("goModLines() diff (-want +got):\n%s", diff)
+	}
+	if got := goModLines(filepath.Join(t.TempDir(), "missing.mod")); len(got) != 0 {
+		t.Errorf("goModLines() of missing file = %v, want empty", got)
+	}
+}
+
+func TestNotifyViolations(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		got = append(got, string(body))
+	}))
+	defer server.Close()
+
+	webhookURL, webhookState = server.URL, filepath.Join(t.TempDir(), "state.json")
+	defer func() { webhookURL, webhookFormat, webhookState = "", webhookFormatJSON, "" }()
+	forbidden := violation{Library: "github.com/logrusorgru/aurora", Module: "github.com/logrusorgru/aurora", Version: "v3.0.0", License: "WTFPL", Rule: "disallowed_types=forbidden", Message: "Forbidden license type WTFPL found for library github.com/logrusorgru/aurora"}
+	unknown := violation{Library: "example.com/foo", Module: "example.com/foo", Version: "v1.0.0", Rule: "disallowed_types=unknown", Message: "Unknown license type  found for library example.com/foo"}
+
+	for _, run := range []struct {
+		format     string
+		violations []violation
+	}{
+		{webhookFormatJSON, []violation{forbidden}},
+		// Only the new violation is posted.
+		{webhookFormatSlack, []violation{forbidden, unknown}},
+		// Nothing new, nothing is posted.
+		{webhookFormatJSON, []violation{forbidden, unknown}},
+	} {
+		webhookFormat = run.format
+		if err := notifyViolations(context.Background(), server.Client(), run.violations); err != nil {
+			t.Fatalf("notifyViolations() = %v, want nil", err)
+		}
+	}
+
+	want := []string{
+		`{"violations":[{"library":"github.com/logrusorgru/aurora","module":"github.com/logrusorgru/aurora","version":"v3.0.0","license":"WTFPL","rule":"disallowed_types=forbidden","message":"Forbidden license type WTFPL found for library github.com/logrusorgru/aurora"}]}`,
+		`{"text":"*go-licenses check* found 1 new license policy violation(s):\n• ` + "`example.com/foo` (module `example.com/foo`)" + `: license Unknown, rule disallowed_types=unknown"}`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("webhook requests diff (-want +got):\n%s", diff)
+	}
+}
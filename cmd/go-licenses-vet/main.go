@@ -0,0 +1,29 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command go-licenses-vet is a go vet tool reporting imports that pull in libraries whose license
+// is not allowed:
+//
+//	go-licenses report ./... --columns=name,license,type --output=licenses.csv
+//	go vet -vettool=$(which go-licenses-vet) -licenses.report=$PWD/licenses.csv ./...
+package main
+
+import (
+	"github.com/nwoodmsft/go-licenses/analyzer"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	unitchecker.Main(analyzer.Analyzer)
+}
@@ -0,0 +1,217 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultConfigPath = ".go-licenses.yaml"
+	envPrefix         = "GO_LICENSES_"
+)
+
+// configPath is the config file to read flag defaults from. When empty,
+// defaultConfigPath is used if it exists.
+var configPath string
+
+// flagSources records the flags that were set from environment variables or the
+// config file, with a description of where the value came from. Such flags are
+// not marked as Changed, which only flags specified on the command line are, so
+// that commands can tell them apart from defaults the user may not know about.
+var flagSources = make(map[*pflag.Flag]string)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Config file to read flag defaults from (default: "+defaultConfigPath+" in the current directory, if it exists)")
+	if err := rootCmd.MarkPersistentFlagFilename("config", "yaml", "yml"); err != nil {
+		klog.Fatal(err)
+	}
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
+		// Precedence: command line flags, environment variables, config file.
+		if err := loadEnv(cmd); err != nil {
+			return err
+		}
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		allowRequiredDefaults(cmd)
+		return nil
+	}
+}
+
+// isFlagSet returns true if flag was specified on the command line, through an
+// environment variable or in the config file.
+func isFlagSet(flag *pflag.Flag) bool {
+	_, ok := flagSources[flag]
+	return flag.Changed || ok
+}
+
+// allowRequiredDefaults lets required flags of cmd be set from environment
+// variables or the config file. cobra only considers flags specified on the
+// command line when validating required flags.
+func allowRequiredDefaults(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if _, ok := flagSources[flag]; ok {
+			delete(flag.Annotations, cobra.BashCompOneRequiredFlag)
+		}
+	})
+}
+
+// loadEnv sets flags of cmd that were not specified on the command line from
+// GO_LICENSES_<FLAG> environment variables, e.g. GO_LICENSES_CONFIDENCE_THRESHOLD.
+// Dashes in flag names are replaced by underscores. List flags accept comma
+// separated values.
+func loadEnv(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if err != nil || isFlagSet(flag) {
+			return
+		}
+		name := envName(flag.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if setErr := flag.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("environment variable %s: %w", name, setErr)
+			return
+		}
+		flagSources[flag] = "environment variable " + name
+	})
+	return err
+}
+
+// envName returns the name of the environment variable setting the flag with the given name.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// loadConfig sets flags of cmd that were not specified on the command line to
+// values from the config file.
+//
+// The config file is a YAML mapping from flag names to values. Top level keys
+// are global flags, flags of a command are nested under the command name:
+//
+//	confidence_threshold: 0.8
+//	ignore:
+//	  - github.com/example-corporation
+//	check:
+//	  allowed_licenses: [Apache-2.0, MIT]
+//
+// Global flags can be nested under a command as well, which takes precedence
+// over the top level value.
+func loadConfig(cmd *cobra.Command) error {
+	path := configPath
+	if path == "" {
+		path = defaultConfigPath
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	values := make(map[string]interface{})
+	var commandValues map[string]interface{}
+	for name, value := range config {
+		if section, ok := value.(map[string]interface{}); ok {
+			if !isCommand(cmd.Root(), name) {
+				return fmt.Errorf("config file %s: unknown command %q", path, name)
+			}
+			if name == cmd.Name() {
+				commandValues = section
+			}
+			continue
+		}
+		if cmd.Root().PersistentFlags().Lookup(name) == nil {
+			if isFlag(cmd.Root(), name) {
+				return fmt.Errorf("config file %s: flag %q is not a global flag, nest it under the command it applies to", path, name)
+			}
+			return fmt.Errorf("config file %s: unknown flag %q", path, name)
+		}
+		values[name] = value
+	}
+	for name, value := range commandValues {
+		if cmd.Flags().Lookup(name) == nil {
+			return fmt.Errorf("config file %s: unknown flag %q for command %q", path, name, cmd.Name())
+		}
+		values[name] = value
+	}
+
+	for name, value := range values {
+		flag := cmd.Flags().Lookup(name)
+		if isFlagSet(flag) {
+			continue
+		}
+		if err := setFlagValue(flag, value); err != nil {
+			return fmt.Errorf("config file %s: setting flag %q: %w", path, name, err)
+		}
+		flagSources[flag] = "config file " + path
+	}
+	return nil
+}
+
+// setFlagValue sets flag to a value decoded from YAML.
+func setFlagValue(flag *pflag.Flag, value interface{}) error {
+	if list, ok := value.([]interface{}); ok {
+		sliceValue, ok := flag.Value.(pflag.SliceValue)
+		if !ok {
+			return fmt.Errorf("flag does not accept a list")
+		}
+		var items []string
+		for _, item := range list {
+			items = append(items, fmt.Sprint(item))
+		}
+		return sliceValue.Replace(items)
+	}
+	return flag.Value.Set(fmt.Sprint(value))
+}
+
+func isCommand(root *cobra.Command, name string) bool {
+	for _, cmd := range root.Commands() {
+		if cmd.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isFlag returns true if any command accepts a flag with the given name.
+func isFlag(root *cobra.Command, name string) bool {
+	if root.PersistentFlags().Lookup(name) != nil {
+		return true
+	}
+	for _, cmd := range root.Commands() {
+		if cmd.Flags().Lookup(name) != nil {
+			return true
+		}
+	}
+	return false
+}
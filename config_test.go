@@ -0,0 +1,270 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// newTestCommands returns a command tree with flags like go-licenses, and its report and check
+// commands.
+func newTestCommands() (root, report, check *cobra.Command) {
+	root = &cobra.Command{Use: "go-licenses"}
+	root.PersistentFlags().Float64("confidence_threshold", 0.9, "")
+	root.PersistentFlags().StringSlice("ignore", nil, "")
+	report = &cobra.Command{Use: "report"}
+	report.Flags().String("format", "csv", "")
+	report.Flags().String("template", "", "")
+	report.Flags().String("output_path", "", "")
+	report.Flags().Bool("dry-run", false, "")
+	check = &cobra.Command{Use: "check"}
+	check.Flags().StringSlice("allowed_licenses", nil, "")
+	check.Flags().String("format", "text", "")
+	root.AddCommand(report, check)
+	return root, report, check
+}
+
+func writeConfig(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := configPath
+	configPath = path
+	t.Cleanup(func() { configPath = old })
+}
+
+func TestLoadConfig(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		config  string
+		command string
+		args    []string
+		// want maps flag names to their expected values.
+		want map[string]string
+		// wantSet lists the flags expected to be set from the config file.
+		wantSet []string
+		wantErr string
+	}{
+		{
+			desc:    "Global flag at the top level",
+			config:  "confidence_threshold: 0.8\nignore: [example.com/a, example.com/b]\n",
+			command: "report",
+			want:    map[string]string{"confidence_threshold": "0.8", "ignore": "[example.com/a,example.com/b]", "format": "csv"},
+			wantSet: []string{"confidence_threshold", "ignore"},
+		},
+		{
+			desc:    "Command flags only apply to their command",
+			config:  "report:\n  format: cyclonedx\ncheck:\n  format: github\n  allowed_licenses: [MIT]\n",
+			command: "check",
+			want:    map[string]string{"format": "github", "allowed_licenses": "[MIT]"},
+			wantSet: []string{"allowed_licenses", "format"},
+		},
+		{
+			desc:    "Command scoped global flag takes precedence",
+			config:  "confidence_threshold: 0.8\nreport:\n  confidence_threshold: 0.7\n",
+			command: "report",
+			want:    map[string]string{"confidence_threshold": "0.7"},
+			wantSet: []string{"confidence_threshold"},
+		},
+		{
+			desc:    "Command line flags take precedence",
+			config:  "report:\n  format: cyclonedx\n  template: notices.tpl\n",
+			command: "report",
+			args:    []string{"--format=gitlab"},
+			want:    map[string]string{"format": "gitlab", "template": "notices.tpl"},
+			wantSet: []string{"template"},
+		},
+		{
+			desc:    "Command flag at the top level",
+			config:  "format: cyclonedx\n",
+			command: "report",
+			wantErr: `flag "format" is not a global flag`,
+		},
+		{
+			desc:    "Unknown top level flag",
+			config:  "colour: red\n",
+			command: "report",
+			wantErr: `unknown flag "colour"`,
+		},
+		{
+			desc:    "Unknown command",
+			config:  "serve:\n  port: 80\n",
+			command: "report",
+			wantErr: `unknown command "serve"`,
+		},
+		{
+			desc:    "Unknown command flag",
+			config:  "report:\n  allowed_licenses: [MIT]\n",
+			command: "report",
+			wantErr: `unknown flag "allowed_licenses" for command "report"`,
+		},
+		{
+			desc:    "List for a scalar flag",
+			config:  "report:\n  format: [csv, gitlab]\n",
+			command: "report",
+			wantErr: "does not accept a list",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			writeConfig(t, test.config)
+			_, report, check := newTestCommands()
+			cmd := report
+			if test.command == "check" {
+				cmd = check
+			}
+			if err := cmd.ParseFlags(test.args); err != nil {
+				t.Fatal(err)
+			}
+			err := loadConfig(cmd)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("loadConfig() = %v, want error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadConfig() = %v", err)
+			}
+			for name, want := range test.want {
+				if got := cmd.Flags().Lookup(name).Value.String(); got != want {
+					t.Errorf("flag %s = %q, want %q", name, got, want)
+				}
+			}
+			var gotSet []string
+			for flag := range flagSources {
+				if cmd.Flags().Lookup(flag.Name) == flag {
+					gotSet = append(gotSet, flag.Name)
+					if flag.Changed {
+						t.Errorf("flag %s from the config file is marked as changed on the command line", flag.Name)
+					}
+				}
+			}
+			if diff := cmp.Diff(test.wantSet, gotSet, cmpopts.SortSlices(func(x, y string) bool { return x < y }), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("flags set from the config file: diff (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConfigSatisfiesRequiredFlags(t *testing.T) {
+	writeConfig(t, "report:\n  output_path: report.csv\n")
+	_, report, _ := newTestCommands()
+	if err := report.MarkFlagRequired("output_path"); err != nil {
+		t.Fatal(err)
+	}
+	if err := report.ParseFlags(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(report); err != nil {
+		t.Fatal(err)
+	}
+	allowRequiredDefaults(report)
+	if err := report.ValidateRequiredFlags(); err != nil {
+		t.Errorf("ValidateRequiredFlags() = %v, want the config file to satisfy the required flag", err)
+	}
+}
+
+func TestFlagPrecedence(t *testing.T) {
+	for _, test := range []struct {
+		desc   string
+		args   []string
+		env    map[string]string
+		config string
+		want   map[string]string
+		// wantChanged lists the flags expected to be marked as specified on the command line.
+		wantChanged []string
+	}{
+		{
+			desc: "Defaults",
+			want: map[string]string{"format": "csv", "confidence_threshold": "0.9", "dry-run": "false"},
+		},
+		{
+			desc:   "Config file",
+			config: "confidence_threshold: 0.8\nreport:\n  format: gitlab\n",
+			want:   map[string]string{"format": "gitlab", "confidence_threshold": "0.8"},
+		},
+		{
+			desc:   "Environment variables take precedence over the config file",
+			env:    map[string]string{"GO_LICENSES_FORMAT": "syft", "GO_LICENSES_IGNORE": "example.com/a,example.com/b"},
+			config: "confidence_threshold: 0.8\nignore: [example.com/c]\nreport:\n  format: gitlab\n",
+			want:   map[string]string{"format": "syft", "confidence_threshold": "0.8", "ignore": "[example.com/a,example.com/b]"},
+		},
+		{
+			desc:        "Command line flags take precedence over environment variables",
+			args:        []string{"--format=ort", "--confidence_threshold=0.5"},
+			env:         map[string]string{"GO_LICENSES_FORMAT": "syft", "GO_LICENSES_CONFIDENCE_THRESHOLD": "0.7"},
+			config:      "confidence_threshold: 0.8\nreport:\n  format: gitlab\n",
+			want:        map[string]string{"format": "ort", "confidence_threshold": "0.5"},
+			wantChanged: []string{"confidence_threshold", "format"},
+		},
+		{
+			desc: "Dashes in flag names are underscores in environment variables",
+			env:  map[string]string{"GO_LICENSES_DRY_RUN": "true"},
+			want: map[string]string{"dry-run": "true"},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			for name, value := range test.env {
+				os.Setenv(name, value)
+				defer os.Unsetenv(name)
+			}
+			writeConfig(t, test.config)
+			_, report, _ := newTestCommands()
+			if err := report.ParseFlags(test.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := loadEnv(report); err != nil {
+				t.Fatalf("loadEnv() = %v", err)
+			}
+			if err := loadConfig(report); err != nil {
+				t.Fatalf("loadConfig() = %v", err)
+			}
+			for name, want := range test.want {
+				if got := report.Flags().Lookup(name).Value.String(); got != want {
+					t.Errorf("flag %s = %q, want %q", name, got, want)
+				}
+			}
+			var gotChanged []string
+			report.Flags().Visit(func(flag *pflag.Flag) {
+				gotChanged = append(gotChanged, flag.Name)
+			})
+			if diff := cmp.Diff(test.wantChanged, gotChanged, cmpopts.SortSlices(func(x, y string) bool { return x < y }), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("changed flags: diff (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for flag, want := range map[string]string{
+		"confidence_threshold": "GO_LICENSES_CONFIDENCE_THRESHOLD",
+		"dry-run":              "GO_LICENSES_DRY_RUN",
+		"v":                    "GO_LICENSES_V",
+	} {
+		if got := envName(flag); got != want {
+			t.Errorf("envName(%q) = %q, want %q", flag, got, want)
+		}
+	}
+}
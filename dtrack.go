@@ -0,0 +1,142 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nwoodmsft/go-licenses/licenses"
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+)
+
+var (
+	dtrackHelp = "Uploads a CycloneDX SBOM of the libraries used by one or more Go packages to a Dependency-Track server."
+	dtrackCmd  = &cobra.Command{
+		Use:   "dtrack <package> [package...]",
+		Short: dtrackHelp,
+		Long:  dtrackHelp + packageHelp,
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  dtrackMain,
+	}
+
+	dtrackURL     string
+	dtrackAPIKey  string
+	dtrackProject string
+)
+
+// dtrackTimeout is the timeout of the upload request.
+const dtrackTimeout = time.Minute
+
+func init() {
+	dtrackCmd.Flags().StringVar(&dtrackURL, "dtrack_url", "", "Base URL of the Dependency-Track API server, e.g. https://dtrack.example.com")
+	dtrackCmd.Flags().StringVar(&dtrackAPIKey, "dtrack_api_key", "", "Dependency-Track API key with the BOM_UPLOAD permission. Prefer setting it with the GO_LICENSES_DTRACK_API_KEY environment variable, to keep it out of process listings.")
+	dtrackCmd.Flags().StringVar(&dtrackProject, "dtrack_project", "", "UUID of the Dependency-Track project to upload the SBOM to")
+	for _, flag := range []string{"dtrack_url", "dtrack_api_key", "dtrack_project"} {
+		if err := dtrackCmd.MarkFlagRequired(flag); err != nil {
+			klog.Fatal(err)
+		}
+	}
+
+	rootCmd.AddCommand(dtrackCmd)
+}
+
+func dtrackMain(_ *cobra.Command, args []string) error {
+	classifier, identifier, err := newClassifiers()
+	if err != nil {
+		return err
+	}
+
+	libs, err := licenses.Libraries(context.Background(), classifier, ignore, nil, args...)
+	if err != nil {
+		return err
+	}
+
+	var bomData []libraryData
+	for _, lib := range libs {
+		libData, err := resolveLibrary(context.Background(), identifier, lib)
+		if err != nil {
+			return err
+		}
+		bomData = append(bomData, libData)
+	}
+
+	var bom bytes.Buffer
+	if err := reportCycloneDX(&bom, bomData); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dtrackTimeout)
+	defer cancel()
+	token, err := uploadBOM(ctx, http.DefaultClient, dtrackURL, dtrackAPIKey, dtrackProject, bom.Bytes())
+	if err != nil {
+		return err
+	}
+	klog.Infof("Uploaded SBOM of %d libraries to project %s, processing token: %s", len(bomData), dtrackProject, token)
+	return nil
+}
+
+// uploadBOM uploads bom to the Dependency-Track project with the given UUID and returns the token
+// identifying the asynchronous processing of the upload.
+func uploadBOM(ctx context.Context, client *http.Client, baseURL, apiKey, project string, bom []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("project", project); err != nil {
+		return "", err
+	}
+	part, err := form.CreateFormFile("bom", "bom.json")
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(bom); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/api/v1/bom", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-Api-Key", apiKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("uploading SBOM to Dependency-Track: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("uploading SBOM to Dependency-Track: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	var result struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("parsing Dependency-Track response: %w", err)
+	}
+	return result.Token, nil
+}
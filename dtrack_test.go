@@ -0,0 +1,73 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUploadBOM(t *testing.T) {
+	bom := []byte(`{"bomFormat": "CycloneDX"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/bom" {
+			http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		if got := r.Header.Get("X-Api-Key"); got != "secret" {
+			http.Error(w, "bad API key "+got, http.StatusUnauthorized)
+			return
+		}
+		if got := r.FormValue("project"); got != "6d8b2a3e-0000-4000-8000-000000000000" {
+			http.Error(w, "bad project "+got, http.StatusBadRequest)
+			return
+		}
+		f, _, err := r.FormFile("bom")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		if got, _ := io.ReadAll(f); string(got) != string(bom) {
+			http.Error(w, "bad bom "+string(got), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"token": "abc-123"}`)
+	}))
+	defer server.Close()
+
+	for _, test := range []struct {
+		desc      string
+		apiKey    string
+		wantToken string
+		wantErr   bool
+	}{
+		{desc: "Success", apiKey: "secret", wantToken: "abc-123"},
+		{desc: "Unauthorized", apiKey: "wrong", wantErr: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			token, err := uploadBOM(context.Background(), server.Client(), server.URL+"/", test.apiKey, "6d8b2a3e-0000-4000-8000-000000000000", bom)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("uploadBOM() = (_, %v), want err? %t", err, test.wantErr)
+			}
+			if token != test.wantToken {
+				t.Errorf("uploadBOM() = %q, want %q", token, test.wantToken)
+			}
+		})
+	}
+}
@@ -41,6 +41,15 @@ func TestReportCommandE2E(t *testing.T) {
 		{"testdata/modules/replace04", nil, "licenses.csv"},
 
 		{"testdata/modules/hello01", []string{"--template", "licenses.tpl"}, "licenses.md"},
+		{"testdata/modules/hello01", []string{"--only_license", "notice"}, "licenses.csv"},
+		{"testdata/modules/hello01", []string{"--exclude_license", "Apache-2.0"}, "licenses-excluded.csv"},
+		{"testdata/modules/hello01", []string{"--exclude_license", "Apache-2.0", "--include_skipped", "--columns", "name,license,status,details"}, "licenses-skipped.csv"},
+		{"testdata/modules/hello01", []string{"--columns", "name,version,license,type,path,dependency"}, "licenses-columns.csv"},
+		{"testdata/modules/hello01", []string{"--granularity", "package", "--columns", "name,library,license"}, "licenses-packages.csv"},
+		{"testdata/modules/hello01", []string{"--format", "gitlab"}, "gl-license-scanning-report.json"},
+		{"testdata/modules/hello01", []string{"--format", "cyclonedx"}, "bom.json"},
+		{"testdata/modules/hello01", []string{"--format", "scancode"}, "scancode.json"},
+		{"testdata/modules/hello01", []string{"--format", "syft"}, "syft.json"},
 		{"testdata/modules/template01", []string{"--template", "licenses.tpl"}, "licenses.md"},
 	}
 
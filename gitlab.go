@@ -0,0 +1,90 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"sort"
+
+	"github.com/nwoodmsft/go-licenses/licenses"
+)
+
+// gitLabReportVersion is the version of the GitLab license scanning report schema written by reportGitLab.
+const gitLabReportVersion = "2.1"
+
+// gitLabReport is a GitLab license scanning report, as defined by the license scanning report
+// JSON schema of GitLab's security products.
+type gitLabReport struct {
+	Version      string             `json:"version"`
+	Licenses     []gitLabLicense    `json:"licenses"`
+	Dependencies []gitLabDependency `json:"dependencies"`
+}
+
+type gitLabLicense struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type gitLabDependency struct {
+	Name           string   `json:"name"`
+	Version        string   `json:"version"`
+	PackageManager string   `json:"package_manager"`
+	Path           string   `json:"path"`
+	Licenses       []string `json:"licenses"`
+}
+
+// reportGitLab writes libs as a GitLab license scanning report, which GitLab shows in merge
+// requests and the license compliance dashboard when uploaded as the
+// artifacts:reports:license_scanning artifact of a CI job.
+func reportGitLab(w io.Writer, libs []libraryData) error {
+	report := gitLabReport{
+		Version:      gitLabReportVersion,
+		Licenses:     []gitLabLicense{},
+		Dependencies: []gitLabDependency{},
+	}
+	seen := make(map[string]bool)
+	for _, lib := range libs {
+		id := gitLabLicenseID(lib.LicenseName)
+		if !seen[id] {
+			seen[id] = true
+			license := gitLabLicense{ID: id, Name: lib.LicenseName}
+			// Only SPDX licenses have a page on spdx.org.
+			if licenses.IsSPDXLicenseID(id) {
+				license.URL = "https://spdx.org/licenses/" + id + ".html"
+			}
+			report.Licenses = append(report.Licenses, license)
+		}
+		report.Dependencies = append(report.Dependencies, gitLabDependency{
+			Name:           lib.Name,
+			Version:        lib.Version,
+			PackageManager: "go",
+			Path:           "go.mod",
+			Licenses:       []string{id},
+		})
+	}
+	sort.Slice(report.Licenses, func(i, j int) bool { return report.Licenses[i].ID < report.Licenses[j].ID })
+
+	return writeJSON(w, report)
+}
+
+// gitLabLicenseID returns the license ID GitLab uses for the license name reported by the
+// classifier, which is usually an SPDX identifier, or Unknown.
+func gitLabLicenseID(licenseName string) string {
+	if licenseName == "" || licenseName == UNKNOWN {
+		return "unknown"
+	}
+	return licenseName
+}
@@ -0,0 +1,58 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReportGitLab(t *testing.T) {
+	libs := []libraryData{
+		{Name: "golang.org/x/text", Version: "v0.3.8", LicenseName: "BSD-3-Clause"},
+		{Name: "golang.org/x/text/internal/foo", Version: "v0.3.8", LicenseName: "BSD-3-Clause"},
+		{Name: "example.com/commons", Version: "v1.0.0", LicenseName: "Commons-Clause"},
+		{Name: "example.com/unlicensed", Version: UNKNOWN, LicenseName: UNKNOWN},
+	}
+	var out strings.Builder
+	if err := reportGitLab(&out, libs); err != nil {
+		t.Fatalf("reportGitLab() = %v, want nil", err)
+	}
+	var got gitLabReport
+	if err := json.Unmarshal([]byte(out.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := gitLabReport{
+		Version: gitLabReportVersion,
+		Licenses: []gitLabLicense{
+			{ID: "BSD-3-Clause", Name: "BSD-3-Clause", URL: "https://spdx.org/licenses/BSD-3-Clause.html"},
+			// Licenses that aren't SPDX licenses have no page on spdx.org.
+			{ID: "Commons-Clause", Name: "Commons-Clause"},
+			{ID: "unknown", Name: UNKNOWN},
+		},
+		Dependencies: []gitLabDependency{
+			{Name: "golang.org/x/text", Version: "v0.3.8", PackageManager: "go", Path: "go.mod", Licenses: []string{"BSD-3-Clause"}},
+			{Name: "golang.org/x/text/internal/foo", Version: "v0.3.8", PackageManager: "go", Path: "go.mod", Licenses: []string{"BSD-3-Clause"}},
+			{Name: "example.com/commons", Version: "v1.0.0", PackageManager: "go", Path: "go.mod", Licenses: []string{"Commons-Clause"}},
+			{Name: "example.com/unlicensed", Version: UNKNOWN, PackageManager: "go", Path: "go.mod", Licenses: []string{"unknown"}},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("reportGitLab() diff (-want +got):\n%s", diff)
+	}
+}
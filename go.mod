@@ -8,12 +8,16 @@ require (
 	github.com/google/go-cmp v0.5.8
 	github.com/google/go-replayers/httpreplay v1.1.1
 	github.com/google/licenseclassifier v0.0.0-20210722185704-3043a050f148
+	github.com/klauspost/compress v1.15.9
 	github.com/kr/text v0.2.0 // indirect
+	github.com/mattn/go-sqlite3 v1.14.15
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
 	github.com/otiai10/copy v1.6.0
 	github.com/pkg/errors v0.9.1 // indirect
+	github.com/santhosh-tekuri/jsonschema/v5 v5.2.0
 	github.com/sergi/go-diff v1.2.0 // indirect
 	github.com/spf13/cobra v1.6.0
+	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.8.0 // indirect
 	go.opencensus.io v0.23.0
 	golang.org/x/crypto v0.0.0-20220722155217-630584e8d5aa // indirect
@@ -24,5 +28,6 @@ require (
 	golang.org/x/tools v0.1.12
 	gopkg.in/check.v1 v1.0.0-20200227125254-8fa46927fb4f // indirect
 	gopkg.in/src-d/go-git.v4 v4.13.1
+	gopkg.in/yaml.v3 v3.0.1
 	k8s.io/klog/v2 v2.80.1
 )
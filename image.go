@@ -0,0 +1,647 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/nwoodmsft/go-licenses/licenses"
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+)
+
+var (
+	imageHelp = "Reports the licenses of the go modules built into the Go binaries of container images."
+	imageCmd  = &cobra.Command{
+		Use:   "image <image> [image...]",
+		Short: imageHelp,
+		Long: imageHelp + `
+
+Each image is a tarball written by "docker save", an OCI image layout tarball,
+e.g. written by "skopeo copy docker://<image> oci-archive:<file>", or a
+directory containing either of them unpacked. Images are not pulled from
+registries. Archives of several images or platforms are scanned in full.
+
+Go binaries are found in the image layers, and the go modules recorded in their
+build info are downloaded with "go mod download" to identify their licenses.
+The report has one entry per module, in the format chosen with --format.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: imageMain,
+	}
+
+	imageOutputPath  string
+	imageCompression string
+	imageFormat      string
+)
+
+func init() {
+	imageCmd.Flags().StringVar(&imageOutputPath, "output", "", "File to write the report to. The file is only replaced once the report is complete. (default: stdout)")
+	imageCmd.Flags().StringVar(&imageCompression, "compress", "", "Compress the report, one of: none, gzip, zstd. (default: inferred from the --output file extension .gz or .zst)")
+	imageCmd.Flags().StringVar(&imageFormat, "format", "csv", "Report format, one of: "+strings.Join(moduleReportFormatNames(), ", ")+", or the name of a format plugin.")
+
+	rootCmd.AddCommand(imageCmd)
+}
+
+// goBuildInfoMagic starts the build info section of Go binaries.
+var goBuildInfoMagic = []byte("\xff Go buildinf:")
+
+// executableMagics start executable files in the formats Go builds: ELF, PE and Mach-O.
+var executableMagics = [][]byte{
+	[]byte("\x7fELF"),
+	[]byte("MZ"),
+	[]byte("\xfe\xed\xfa\xce"), []byte("\xfe\xed\xfa\xcf"),
+	[]byte("\xce\xfa\xed\xfe"), []byte("\xcf\xfa\xed\xfe"),
+}
+
+// moduleVersion is a version of a go module, e.g. one built into a binary.
+type moduleVersion struct {
+	Path    string
+	Version string
+}
+
+func imageMain(_ *cobra.Command, args []string) error {
+	writeReport, err := moduleReportWriter(imageFormat)
+	if err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp("", "go-licenses-image")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	modules := make(map[moduleVersion]bool)
+	for i, image := range args {
+		binaries, err := imageBinaries(image, filepath.Join(tmpDir, fmt.Sprint(i)))
+		if err != nil {
+			return fmt.Errorf("scanning image %s: %w", image, err)
+		}
+		if len(binaries) == 0 {
+			klog.Warningf("Image %s does not contain Go binaries", image)
+		}
+		for name, file := range binaries {
+			mods, err := binaryModules(file)
+			if err != nil {
+				return fmt.Errorf("reading build info of %s in image %s: %w", name, image, err)
+			}
+			klog.V(2).Infof("Found Go binary %s with %d modules in image %s", name, len(mods), image)
+			for _, m := range mods {
+				if !isIgnored(m.Path) {
+					modules[m] = true
+				}
+			}
+		}
+	}
+
+	var sorted []moduleVersion
+	for m := range modules {
+		sorted = append(sorted, m)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Path != sorted[j].Path {
+			return sorted[i].Path < sorted[j].Path
+		}
+		return sorted[i].Version < sorted[j].Version
+	})
+	dirs, err := downloadModules(context.Background(), sorted)
+	if err != nil {
+		return err
+	}
+	var mods []licenses.Module
+	for _, m := range sorted {
+		mods = append(mods, licenses.Module{Path: m.Path, Version: m.Version, Dir: dirs[m]})
+	}
+	return writeModuleReport(writeReport, mods, imageOutputPath, imageCompression)
+}
+
+// moduleReportWriter returns the writer of reports of whole go modules in the given format.
+func moduleReportWriter(format string) (func(w io.Writer, libs []libraryData) error, error) {
+	writeReport, ok := reportFormats[format]
+	if !ok {
+		writeReport = pluginFormatter(format)
+		ok = writeReport != nil
+	}
+	if !ok || format == reportFormatSQLite {
+		return nil, fmt.Errorf("unknown format %q, supported formats: %s", format, strings.Join(moduleReportFormatNames(), ", "))
+	}
+	return writeReport, nil
+}
+
+// moduleReportFormatNames returns the sorted names of the report formats supported by
+// moduleReportWriter, which can't write SQLite databases: only report writes them.
+func moduleReportFormatNames() []string {
+	var names []string
+	for _, name := range reportFormatNames() {
+		if name != reportFormatSQLite {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// writeModuleReport identifies the licenses of whole go modules, whose files are in their Dir, and
+// writes the report to path, see writeOutput.
+func writeModuleReport(writeReport func(w io.Writer, libs []libraryData) error, modules []licenses.Module, path, compression string) error {
+	classifier, identifier, err := newClassifiers()
+	if err != nil {
+		return err
+	}
+	var reportData []libraryData
+	for _, m := range modules {
+		lib := licenses.ModuleLibrary(m, classifier)
+		libData, err := resolveLibrary(context.Background(), identifier, lib)
+		if err != nil {
+			return err
+		}
+		reportData = append(reportData, libData)
+	}
+	return writeOutput(path, compression, func(w io.Writer) error {
+		return writeReport(w, reportData)
+	})
+}
+
+// isIgnored returns whether the module path matches one of the --ignore prefixes.
+func isIgnored(modulePath string) bool {
+	for _, prefix := range ignore {
+		if strings.HasPrefix(modulePath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageBinaries finds the Go binaries in the image at path, a tarball or directory, and copies
+// them to dir. It returns the copies keyed by their path in the image, prefixed with the index of
+// the image in archives of several images.
+func imageBinaries(path, dir string) (map[string]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	layoutDir := path
+	if !info.IsDir() {
+		layoutDir = filepath.Join(dir, "layout")
+		if err := extractTar(path, layoutDir); err != nil {
+			return nil, err
+		}
+	}
+	images, err := imageLayers(layoutDir)
+	if err != nil {
+		return nil, err
+	}
+	binaries := make(map[string]string)
+	for i, layers := range images {
+		found, err := scanLayers(layers, filepath.Join(dir, "binaries", fmt.Sprint(i)))
+		if err != nil {
+			return nil, err
+		}
+		for name, file := range found {
+			if len(images) > 1 {
+				name = fmt.Sprintf("%d:%s", i, name)
+			}
+			binaries[name] = file
+		}
+	}
+	return binaries, nil
+}
+
+// extractTar extracts the regular files of the tarball at path to dir.
+func extractTar(path, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := tarEntryPath(header.Name)
+		if name == "" {
+			return fmt.Errorf("invalid file name %q in %s", header.Name, path)
+		}
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := writeFile(target, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// tarEntryPath returns the slash separated name of a tarball entry as a clean relative local path,
+// or "" if it's outside of the tarball's root.
+func tarEntryPath(name string) string {
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		return ""
+	}
+	return filepath.FromSlash(name)
+}
+
+func writeFile(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// imageLayers returns the paths of the layer files of each image in dir, an unpacked "docker
+// save" tarball or OCI image layout, in the order they are applied.
+func imageLayers(dir string) ([][]string, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err == nil {
+		var manifests []struct {
+			Layers []string
+		}
+		if err := json.Unmarshal(content, &manifests); err != nil {
+			return nil, fmt.Errorf("parsing manifest.json: %w", err)
+		}
+		var images [][]string
+		for _, m := range manifests {
+			var layers []string
+			for _, layer := range m.Layers {
+				layers = append(layers, filepath.Join(dir, tarEntryPath(layer)))
+			}
+			images = append(images, layers)
+		}
+		return images, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.json")); err != nil {
+		return nil, fmt.Errorf("neither manifest.json nor index.json found, not a docker save tarball or OCI image layout")
+	}
+	return ociImageLayers(dir, filepath.Join(dir, "index.json"))
+}
+
+// ociDescriptor is an OCI content descriptor.
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+// ociImageLayers returns the layers of each image referenced by the OCI index or image manifest
+// at path, in the OCI image layout in dir.
+func ociImageLayers(dir, path string) ([][]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		MediaType string          `json:"mediaType"`
+		Manifests []ociDescriptor `json:"manifests"`
+		Layers    []ociDescriptor `json:"layers"`
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if doc.Manifests == nil {
+		var layers []string
+		for _, layer := range doc.Layers {
+			blob, err := ociBlob(dir, layer.Digest)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, blob)
+		}
+		return [][]string{layers}, nil
+	}
+	var images [][]string
+	for _, m := range doc.Manifests {
+		if strings.Contains(m.MediaType, "attestation") {
+			continue
+		}
+		blob, err := ociBlob(dir, m.Digest)
+		if err != nil {
+			return nil, err
+		}
+		nested, err := ociImageLayers(dir, blob)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, nested...)
+	}
+	return images, nil
+}
+
+// ociBlob returns the path of the blob with the given digest in the OCI image layout in dir.
+func ociBlob(dir, digest string) (string, error) {
+	split := strings.SplitN(digest, ":", 2)
+	if len(split) != 2 || strings.ContainsAny(digest, `/\`) {
+		return "", fmt.Errorf("invalid digest %q", digest)
+	}
+	return filepath.Join(dir, "blobs", split[0], split[1]), nil
+}
+
+// scanLayers applies the layers in order, and copies the Go binaries of the resulting file system
+// to dir. It returns the copies keyed by their slash separated path in the image.
+func scanLayers(layers []string, dir string) (map[string]string, error) {
+	binaries := make(map[string]string)
+	for i, layer := range layers {
+		// Whiteouts only delete files of lower layers, so the binaries of this layer are kept
+		// apart until it's applied: an opaque whiteout may follow the files it keeps.
+		layerBinaries := make(map[string]string)
+		err := readLayer(layer, func(header *tar.Header, r io.Reader) error {
+			name := path.Clean("/" + header.Name)[1:]
+			base := path.Base(name)
+			if strings.HasPrefix(base, ".wh.") {
+				deleted := path.Join(path.Dir(name), strings.TrimPrefix(base, ".wh."))
+				if base == ".wh..wh..opq" {
+					deleted = path.Dir(name)
+				}
+				for binary := range binaries {
+					if binary == deleted || strings.HasPrefix(binary, deleted+"/") {
+						delete(binaries, binary)
+					}
+				}
+				return nil
+			}
+			// Files replaced by later layers are gone as well.
+			delete(binaries, name)
+			delete(layerBinaries, name)
+			switch header.Typeflag {
+			case tar.TypeLink:
+				// Hard links share the file they link to, which precedes them in the layer or is in
+				// a lower one.
+				target := path.Clean("/" + header.Linkname)[1:]
+				file, ok := layerBinaries[target]
+				if !ok {
+					file, ok = binaries[target]
+				}
+				if ok {
+					layerBinaries[name] = file
+				}
+				return nil
+			case tar.TypeReg:
+			default:
+				return nil
+			}
+			if header.Mode&0111 == 0 {
+				return nil
+			}
+			target := filepath.Join(dir, fmt.Sprint(i), tarEntryPath(name))
+			ok, err := copyGoBinary(r, target)
+			if err != nil || !ok {
+				return err
+			}
+			layerBinaries[name] = target
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %s: %w", filepath.Base(layer), err)
+		}
+		for name, file := range layerBinaries {
+			binaries[name] = file
+		}
+	}
+	return binaries, nil
+}
+
+// readLayer calls fn for every entry of the layer tarball at path, which may be compressed with
+// gzip or zstd.
+func readLayer(path string, fn func(header *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	var r io.Reader = br
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// copyGoBinary copies an executable file to target, and returns whether it's a Go binary with
+// build info. Other files are not copied. The file is streamed, as binaries may be large.
+func copyGoBinary(r io.Reader, target string) (bool, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	executable := false
+	for _, m := range executableMagics {
+		if bytes.HasPrefix(magic, m) {
+			executable = true
+			break
+		}
+	}
+	if !executable {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return false, err
+	}
+	scanner := &magicScanner{magic: goBuildInfoMagic}
+	if err := writeFile(target, io.TeeReader(br, scanner)); err != nil {
+		return false, err
+	}
+	if !scanner.found {
+		return false, os.Remove(target)
+	}
+	return true, nil
+}
+
+// magicScanner is a writer recording whether magic occurs in the bytes written to it, including
+// across writes.
+type magicScanner struct {
+	magic []byte
+	found bool
+	// tail is the end of the bytes written so far, shorter than magic.
+	tail []byte
+}
+
+func (s *magicScanner) Write(p []byte) (int, error) {
+	if s.found {
+		return len(p), nil
+	}
+	keep := len(s.magic) - 1
+	head := p
+	if len(head) > keep {
+		head = head[:keep]
+	}
+	if bytes.Contains(append(s.tail, head...), s.magic) || bytes.Contains(p, s.magic) {
+		s.found = true
+		return len(p), nil
+	}
+	if len(p) >= keep {
+		s.tail = append(s.tail[:0], p[len(p)-keep:]...)
+		return len(p), nil
+	}
+	s.tail = append(s.tail, p...)
+	if len(s.tail) > keep {
+		s.tail = s.tail[len(s.tail)-keep:]
+	}
+	return len(p), nil
+}
+
+// binaryModules returns the go modules built into the Go binary at path, as reported by
+// "go version -m". Replaced modules are returned as their replacement. The main module is only
+// returned if it has a version of a clean checkout, e.g. the binary was built with
+// "go install <module>@<version>".
+func binaryModules(path string) ([]moduleVersion, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", "version", "-m", path)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go version -m: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseBuildInfo(string(out)), nil
+}
+
+// parseBuildInfo parses the output of "go version -m".
+func parseBuildInfo(out string) []moduleVersion {
+	var modules []moduleVersion
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if !strings.HasPrefix(line, "\t") || len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "mod", "dep":
+			m := moduleVersion{Path: fields[1]}
+			if len(fields) > 2 {
+				m.Version = fields[2]
+			}
+			if fields[0] == "mod" && (m.Version == "" || m.Version == "(devel)" || strings.HasSuffix(m.Version, "+dirty")) {
+				continue
+			}
+			modules = append(modules, m)
+		case "=>":
+			// Replaces the module of the previous line.
+			if len(modules) == 0 {
+				continue
+			}
+			m := moduleVersion{Path: fields[1]}
+			if len(fields) > 2 && !strings.HasPrefix(fields[2], "h1:") {
+				m.Version = fields[2]
+			}
+			modules[len(modules)-1] = m
+		}
+	}
+	return modules
+}
+
+// downloadModules downloads the modules with "go mod download" and returns their directories.
+// Modules that can't be downloaded, e.g. modules replaced by local directories, are logged and
+// missing from the result.
+func downloadModules(ctx context.Context, modules []moduleVersion) (map[moduleVersion]string, error) {
+	dirs := make(map[moduleVersion]string)
+	var args []string
+	for _, m := range modules {
+		if m.Version == "" {
+			klog.Errorf("Module %s has no version, e.g. it's replaced by a local directory, and can't be downloaded", m.Path)
+			continue
+		}
+		args = append(args, m.Path+"@"+m.Version)
+	}
+	if len(args) == 0 {
+		return dirs, nil
+	}
+	tmpDir, err := os.MkdirTemp("", "go-licenses-download")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", append([]string{"mod", "download", "-json"}, args...)...)
+	// Download outside of any module, so that its go.mod doesn't affect the versions.
+	cmd.Dir = tmpDir
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("go mod download: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		var result struct {
+			Path    string
+			Version string
+			Dir     string
+			Error   string
+		}
+		if err := dec.Decode(&result); err != nil {
+			return nil, fmt.Errorf("parsing go mod download output: %w", err)
+		}
+		if result.Error != "" {
+			klog.Errorf("Failed to download module %s@%s: %s", result.Path, result.Version, result.Error)
+			continue
+		}
+		dirs[moduleVersion{Path: result.Path, Version: result.Version}] = result.Dir
+	}
+	return dirs, nil
+}
@@ -0,0 +1,276 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeGoBinary is an ELF file with a build info section, good enough to be recognized as a Go binary.
+var fakeGoBinary = append([]byte("\x7fELF\x02\x01\x01"), goBuildInfoMagic...)
+
+type tarEntry struct {
+	name    string
+	mode    int64
+	content []byte
+	// link is the target of a hard link entry.
+	link string
+}
+
+func tarball(t *testing.T, entries []tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: e.mode, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		if e.link != "" {
+			header.Typeflag, header.Linkname = tar.TypeLink, e.link
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(e.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeBlob writes content to the OCI image layout in dir and returns its digest.
+func writeBlob(t *testing.T, dir string, content []byte) string {
+	t.Helper()
+	hash := fmt.Sprintf("%x", sha256.Sum256(content))
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "blobs", "sha256", hash), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return "sha256:" + hash
+}
+
+func TestImageBinaries(t *testing.T) {
+	layout := t.TempDir()
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	if _, err := gw.Write(tarball(t, []tarEntry{
+		{name: "usr/bin/app", mode: 0755, content: fakeGoBinary},
+		{name: "usr/bin/removed", mode: 0755, content: fakeGoBinary},
+		{name: "usr/lib/not-executable", mode: 0644, content: fakeGoBinary},
+		{name: "usr/bin/script", mode: 0755, content: []byte("#!/bin/sh\n")},
+		{name: "opt/tool/tool", mode: 0755, content: fakeGoBinary},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	base := writeBlob(t, layout, gzipped.Bytes())
+	top := writeBlob(t, layout, tarball(t, []tarEntry{
+		{name: "usr/bin/.wh.removed"},
+		{name: "opt/tool/.wh..wh..opq"},
+	}))
+	manifest := writeBlob(t, layout, []byte(fmt.Sprintf(`{
+		"mediaType": "application/vnd.oci.image.manifest.v1+json",
+		"layers": [
+			{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": %q},
+			{"mediaType": "application/vnd.oci.image.layer.v1.tar", "digest": %q}
+		]
+	}`, base, top)))
+	index := fmt.Sprintf(`{"manifests": [{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": %q}]}`, manifest)
+	if err := os.WriteFile(filepath.Join(layout, "index.json"), []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The layout is scanned both unpacked and as a tarball.
+	var entries []tarEntry
+	err := filepath.Walk(layout, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		rel, _ := filepath.Rel(layout, path)
+		entries = append(entries, tarEntry{name: filepath.ToSlash(rel), mode: 0644, content: content})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(t.TempDir(), "image.tar")
+	if err := os.WriteFile(archive, tarball(t, entries), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, image := range []string{layout, archive} {
+		binaries, err := imageBinaries(image, t.TempDir())
+		if err != nil {
+			t.Fatalf("imageBinaries(%s): %v", image, err)
+		}
+		var names []string
+		for name, file := range binaries {
+			names = append(names, name)
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(content, fakeGoBinary) {
+				t.Errorf("imageBinaries(%s): copy of %s has content %q, want %q", image, name, content, fakeGoBinary)
+			}
+		}
+		if diff := cmp.Diff([]string{"usr/bin/app"}, names); diff != "" {
+			t.Errorf("imageBinaries(%s): diff (-want +got)\n%s", image, diff)
+		}
+	}
+}
+
+func TestImageBinariesLayers(t *testing.T) {
+	// Binaries larger than the buffers they are streamed through are copied whole.
+	largeGoBinary := append([]byte("\x7fELF"), bytes.Repeat([]byte{0}, 64*1024)...)
+	largeGoBinary = append(largeGoBinary, goBuildInfoMagic...)
+	layers := [][]tarEntry{
+		{
+			{name: "usr/bin/replaced", mode: 0755, content: fakeGoBinary},
+			{name: "usr/bin/removed", mode: 0755, content: fakeGoBinary},
+			{name: "opt/tool/lower", mode: 0755, content: fakeGoBinary},
+			{name: "usr/bin/large", mode: 0755, content: largeGoBinary},
+		},
+		{
+			{name: "usr/bin/replaced", mode: 0755, content: []byte("#!/bin/sh\n")},
+			{name: "usr/bin/.wh.removed"},
+			// The opaque whiteout only hides the lower layer's files of the directory.
+			{name: "opt/tool/upper", mode: 0755, content: fakeGoBinary},
+			{name: "opt/tool/.wh..wh..opq"},
+			{name: "usr/local/bin/app", mode: 0755, content: fakeGoBinary},
+			{name: "usr/bin/app", link: "usr/local/bin/app"},
+			{name: "usr/sbin/large", link: "usr/bin/large"},
+		},
+	}
+	image := filepath.Join(t.TempDir(), "image.tar")
+	manifest := `[{"Config": "config.json", "Layers": ["0/layer.tar", "1/layer.tar"]}]`
+	entries := []tarEntry{{name: "manifest.json", mode: 0644, content: []byte(manifest)}}
+	for i, layer := range layers {
+		entries = append(entries, tarEntry{name: fmt.Sprintf("%d/layer.tar", i), mode: 0644, content: tarball(t, layer)})
+	}
+	if err := os.WriteFile(image, tarball(t, entries), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	binaries, err := imageBinaries(image, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][]byte)
+	for name, file := range binaries {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[name] = content
+	}
+	want := map[string][]byte{
+		"usr/bin/large":     largeGoBinary,
+		"opt/tool/upper":    fakeGoBinary,
+		"usr/local/bin/app": fakeGoBinary,
+		"usr/bin/app":       fakeGoBinary,
+		"usr/sbin/large":    largeGoBinary,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("imageBinaries() diff (-want +got)\n%s", diff)
+	}
+}
+
+func TestModuleReportWriter(t *testing.T) {
+	if _, err := moduleReportWriter("csv"); err != nil {
+		t.Errorf("moduleReportWriter(%q) = (_, %v), want (_, nil)", "csv", err)
+	}
+	_, err := moduleReportWriter(reportFormatSQLite)
+	if err == nil {
+		t.Fatalf("moduleReportWriter(%q) = (_, nil), want an error", reportFormatSQLite)
+	}
+	supported := err.Error()[strings.Index(err.Error(), "supported formats: ")+len("supported formats: "):]
+	for _, name := range strings.Split(supported, ", ") {
+		if name == reportFormatSQLite {
+			t.Errorf("moduleReportWriter(%q) = (_, %q), want %s not listed as supported", reportFormatSQLite, err, reportFormatSQLite)
+		}
+	}
+}
+
+func TestMagicScanner(t *testing.T) {
+	content := []byte("prefix" + string(goBuildInfoMagic) + "suffix")
+	// The magic is found wherever the content is split into writes.
+	for i := 0; i <= len(content); i++ {
+		for j := i; j <= len(content); j++ {
+			s := &magicScanner{magic: goBuildInfoMagic}
+			for _, p := range [][]byte{content[:i], content[i:j], content[j:]} {
+				if _, err := s.Write(p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !s.found {
+				t.Errorf("magic not found in writes split at %d and %d", i, j)
+			}
+		}
+	}
+	s := &magicScanner{magic: goBuildInfoMagic}
+	for _, p := range [][]byte{goBuildInfoMagic[:5], []byte("x"), goBuildInfoMagic[5:]} {
+		if _, err := s.Write(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.found {
+		t.Error("magic found in interrupted writes")
+	}
+}
+
+func TestParseBuildInfo(t *testing.T) {
+	out := `/usr/bin/app: go1.19.3
+	path	example.com/app/cmd/app
+	mod	example.com/app	v1.2.0	h1:abc=
+	dep	github.com/google/uuid	v1.3.0	h1:def=
+	dep	golang.org/x/sys	v0.1.0
+	=>	golang.org/x/sys	v0.2.0	h1:ghi=
+	dep	example.com/local	v0.0.0
+	=>	../local
+	build	-compiler=gc
+	build	CGO_ENABLED=1
+`
+	want := []moduleVersion{
+		{Path: "example.com/app", Version: "v1.2.0"},
+		{Path: "github.com/google/uuid", Version: "v1.3.0"},
+		{Path: "golang.org/x/sys", Version: "v0.2.0"},
+		{Path: "../local"},
+	}
+	if diff := cmp.Diff(want, parseBuildInfo(out)); diff != "" {
+		t.Errorf("parseBuildInfo(): diff (-want +got)\n%s", diff)
+	}
+
+	devel := "/app: go1.19.3\n\tpath\texample.com/app\n\tmod\texample.com/app\t(devel)\t\n"
+	if got := parseBuildInfo(devel); len(got) != 0 {
+		t.Errorf("parseBuildInfo(devel) = %v, want no modules", got)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+var (
+	jiraURL             string
+	jiraProject         string
+	jiraIssueType       string
+	jiraUser            string
+	jiraToken           string
+	jiraCloseTransition string
+)
+
+const (
+	// jiraLabel is the label of all issues managed by go-licenses.
+	jiraLabel = "go-licenses"
+	// jiraKeyLabelPrefix prefixes the label identifying the violation an issue is about.
+	jiraKeyLabelPrefix = "go-licenses-"
+	// jiraScopeLabelPrefix prefixes the label identifying the main module checked, so that the
+	// issues of other repositories in the same project are left alone.
+	jiraScopeLabelPrefix = "go-licenses-scope-"
+	// jiraPageSize is the number of issues searched per request, the maximum of Jira Cloud.
+	jiraPageSize = 100
+	// jiraTimeout is the timeout of synchronizing issues with Jira.
+	jiraTimeout = 2 * time.Minute
+)
+
+func init() {
+	checkCmd.Flags().StringVar(&jiraURL, "jira_url", "", "Base URL of a Jira server to open an issue in per new violation, and to close it once resolved, e.g. https://example.atlassian.net")
+	checkCmd.Flags().StringVar(&jiraProject, "jira_project", "", "Key of the Jira project to open issues in")
+	checkCmd.Flags().StringVar(&jiraIssueType, "jira_issue_type", "Task", "Type of the Jira issues to open")
+	checkCmd.Flags().StringVar(&jiraUser, "jira_user", "", "Jira user, e.g. the email address of an Atlassian account. If empty, --jira_token is used as a personal access token.")
+	checkCmd.Flags().StringVar(&jiraToken, "jira_token", "", "Jira API token or personal access token. Prefer setting it with the GO_LICENSES_JIRA_TOKEN environment variable.")
+	checkCmd.Flags().StringVar(&jiraCloseTransition, "jira_close_transition", "Done", "Name of the workflow transition closing resolved issues")
+}
+
+// jiraClient calls the Jira REST API.
+type jiraClient struct {
+	client  *http.Client
+	baseURL string
+	user    string
+	token   string
+}
+
+type jiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Labels []string `json:"labels"`
+	} `json:"fields"`
+}
+
+// syncJiraIssues opens an issue for each module and rule with violations that has no open issue,
+// and closes the open issues of modules and rules without violations. Only the issues of scope,
+// the main module or packages checked, are synchronized.
+func syncJiraIssues(ctx context.Context, jira *jiraClient, scope string, violations []violation) error {
+	ctx, cancel := context.WithTimeout(ctx, jiraTimeout)
+	defer cancel()
+
+	// Violations are tracked per module and rule.
+	byLabel := make(map[string][]violation)
+	for _, v := range violations {
+		label := jiraKeyLabel(v)
+		byLabel[label] = append(byLabel[label], v)
+	}
+
+	scopeLabel := jiraScopeLabel(scope)
+	jql := fmt.Sprintf(`project = %s AND labels = %s AND labels = %s AND statusCategory != Done`, jqlQuote(jiraProject), jqlQuote(jiraLabel), jqlQuote(scopeLabel))
+	issues, err := jira.search(ctx, jql)
+	if err != nil {
+		return err
+	}
+	open := make(map[string]bool)
+	for _, issue := range issues {
+		label := ""
+		for _, l := range issue.Fields.Labels {
+			if strings.HasPrefix(l, jiraKeyLabelPrefix) && !strings.HasPrefix(l, jiraScopeLabelPrefix) {
+				label = l
+			}
+		}
+		if label == "" {
+			continue
+		}
+		open[label] = true
+		if _, ok := byLabel[label]; !ok {
+			if err := jira.close(ctx, issue.Key); err != nil {
+				return err
+			}
+			klog.Infof("Closed resolved Jira issue %s", issue.Key)
+		}
+	}
+
+	var labels []string
+	for label := range byLabel {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		if open[label] {
+			continue
+		}
+		key, err := jira.create(ctx, scope, []string{scopeLabel, label}, byLabel[label])
+		if err != nil {
+			return err
+		}
+		klog.Infof("Opened Jira issue %s for %s", key, byLabel[label][0].Module)
+	}
+	return nil
+}
+
+// jiraScopeLabel returns the label identifying the issues of scope. Labels can't contain spaces,
+// so scope is hashed.
+func jiraScopeLabel(scope string) string {
+	return fmt.Sprintf("%s%x", jiraScopeLabelPrefix, sha256.Sum256([]byte(scope)))[:len(jiraScopeLabelPrefix)+16]
+}
+
+// jqlQuote returns s as a JQL string literal.
+func jqlQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s) + `"`
+}
+
+// search returns all issues matching jql, requesting them page by page.
+func (j *jiraClient) search(ctx context.Context, jql string) ([]jiraIssue, error) {
+	var issues []jiraIssue
+	for {
+		var page struct {
+			Issues []jiraIssue `json:"issues"`
+			Total  int         `json:"total"`
+		}
+		query := url.Values{"jql": {jql}, "fields": {"labels"}, "startAt": {fmt.Sprint(len(issues))}, "maxResults": {fmt.Sprint(jiraPageSize)}}
+		if err := j.do(ctx, http.MethodGet, "/rest/api/2/search?"+query.Encode(), nil, &page); err != nil {
+			return nil, fmt.Errorf("searching Jira issues: %w", err)
+		}
+		issues = append(issues, page.Issues...)
+		if len(page.Issues) == 0 || len(issues) >= page.Total {
+			return issues, nil
+		}
+	}
+}
+
+// jiraKeyLabel returns the label identifying the issue about the module and rule of v. Labels
+// can't contain spaces, so the module and rule are hashed.
+func jiraKeyLabel(v violation) string {
+	module := v.Module
+	if module == "" {
+		module = v.Library
+	}
+	return fmt.Sprintf("%s%x", jiraKeyLabelPrefix, sha256.Sum256([]byte(module+" "+v.Rule)))[:len(jiraKeyLabelPrefix)+16]
+}
+
+func (j *jiraClient) create(ctx context.Context, scope string, labels []string, violations []violation) (string, error) {
+	v := violations[0]
+	module := v.Module
+	if module == "" {
+		module = v.Library
+	}
+	var description strings.Builder
+	fmt.Fprintf(&description, "go-licenses check of %s found libraries of module %s violating the license policy rule %s:\n", scope, module, v.Rule)
+	for _, v := range violations {
+		license := v.License
+		if license == "" {
+			license = UNKNOWN
+		}
+		fmt.Fprintf(&description, "\n* %s %s: %s license", v.Library, v.Version, license)
+	}
+	description.WriteString("\n\nThis issue is closed automatically once the violation is resolved.")
+
+	req := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": jiraProject},
+			"issuetype":   map[string]string{"name": jiraIssueType},
+			"summary":     fmt.Sprintf("License policy violation: %s (%s)", module, v.Rule),
+			"description": description.String(),
+			"labels":      append([]string{jiraLabel}, labels...),
+		},
+	}
+	var created jiraIssue
+	if err := j.do(ctx, http.MethodPost, "/rest/api/2/issue", req, &created); err != nil {
+		return "", fmt.Errorf("creating Jira issue for %s: %w", module, err)
+	}
+	return created.Key, nil
+}
+
+func (j *jiraClient) close(ctx context.Context, key string) error {
+	var transitions struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}
+	if err := j.do(ctx, http.MethodGet, "/rest/api/2/issue/"+url.PathEscape(key)+"/transitions", nil, &transitions); err != nil {
+		return fmt.Errorf("closing Jira issue %s: %w", key, err)
+	}
+	for _, t := range transitions.Transitions {
+		if !strings.EqualFold(t.Name, jiraCloseTransition) {
+			continue
+		}
+		req := map[string]interface{}{
+			"transition": map[string]string{"id": t.ID},
+			"update": map[string]interface{}{
+				"comment": []interface{}{map[string]interface{}{"add": map[string]string{"body": "go-licenses check no longer finds this violation."}}},
+			},
+		}
+		if err := j.do(ctx, http.MethodPost, "/rest/api/2/issue/"+url.PathEscape(key)+"/transitions", req, nil); err != nil {
+			return fmt.Errorf("closing Jira issue %s: %w", key, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("closing Jira issue %s: no transition named %q", key, jiraCloseTransition)
+}
+
+// do sends a request with the JSON encoding of body, if not nil, and decodes the JSON response
+// into result, if not nil.
+func (j *jiraClient) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(content)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(j.baseURL, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if j.user != "" {
+		req.SetBasicAuth(j.user, j.token)
+	} else if j.token != "" {
+		req.Header.Set("Authorization", "Bearer "+j.token)
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	if result == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, result)
+}
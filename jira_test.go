@@ -0,0 +1,187 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeJira is a Jira server with the issues labelled by go-licenses.
+type fakeJira struct {
+	t      *testing.T
+	issues map[string][]string // labels by issue key
+	closed []string
+	// wantJQL is the search query expected.
+	wantJQL string
+}
+
+func (f *fakeJira) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, token, ok := r.BasicAuth(); !ok || user != "bot@example.com" || token != "secret" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/search":
+		if got := r.URL.Query().Get("jql"); got != f.wantJQL {
+			f.t.Errorf("jql = %q, want %q", got, f.wantJQL)
+		}
+		// Results are paged by one issue, less than requested, as Jira may cap them.
+		var keys []string
+		for key := range f.issues {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		startAt, err := strconv.Atoi(r.URL.Query().Get("startAt"))
+		if err != nil {
+			f.t.Errorf("startAt: %v", err)
+		}
+		result := struct {
+			Issues []jiraIssue `json:"issues"`
+			Total  int         `json:"total"`
+		}{Issues: []jiraIssue{}, Total: len(keys)}
+		if startAt < len(keys) {
+			issue := jiraIssue{Key: keys[startAt]}
+			issue.Fields.Labels = f.issues[keys[startAt]]
+			result.Issues = append(result.Issues, issue)
+		}
+		json.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue":
+		var req struct {
+			Fields struct {
+				Project   struct{ Key string }  `json:"project"`
+				IssueType struct{ Name string } `json:"issuetype"`
+				Summary   string                `json:"summary"`
+				Labels    []string              `json:"labels"`
+			} `json:"fields"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			f.t.Error(err)
+		}
+		if req.Fields.Project.Key != "LEGAL" || req.Fields.IssueType.Name != "Task" {
+			f.t.Errorf("created issue in %+v, want project LEGAL and type Task", req.Fields)
+		}
+		key := "LEGAL-" + req.Fields.Summary
+		f.issues[key] = req.Fields.Labels
+		json.NewEncoder(w).Encode(map[string]string{"key": key})
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/transitions"):
+		w.Write([]byte(`{"transitions": [{"id": "11", "name": "In Progress"}, {"id": "31", "name": "Done"}]}`))
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/transitions"):
+		key := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/rest/api/2/issue/"), "/transitions")
+		var req struct {
+			Transition struct{ ID string } `json:"transition"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			f.t.Error(err)
+		}
+		if req.Transition.ID != "31" {
+			f.t.Errorf("transition = %q, want 31", req.Transition.ID)
+		}
+		delete(f.issues, key)
+		f.closed = append(f.closed, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func TestSyncJiraIssues(t *testing.T) {
+	scopeLabel := jiraScopeLabel("example.com/app")
+	fake := &fakeJira{
+		t:       t,
+		issues:  make(map[string][]string),
+		wantJQL: `project = "LEGAL" AND labels = "go-licenses" AND labels = "` + scopeLabel + `" AND statusCategory != Done`,
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	jiraProject = "LEGAL"
+	defer func() { jiraProject = "" }()
+	jira := &jiraClient{client: server.Client(), baseURL: server.URL, user: "bot@example.com", token: "secret"}
+
+	aurora := violation{Library: "github.com/logrusorgru/aurora", Module: "github.com/logrusorgru/aurora", License: "WTFPL", Rule: "disallowed_types=forbidden"}
+	auroraSub := violation{Library: "github.com/logrusorgru/aurora/sub", Module: "github.com/logrusorgru/aurora", License: "WTFPL", Rule: "disallowed_types=forbidden"}
+	foo := violation{Library: "example.com/foo", Module: "example.com/foo", Rule: "disallowed_types=unknown"}
+
+	for _, run := range []struct {
+		violations []violation
+		wantOpen   []string
+		wantClosed []string
+	}{
+		{
+			// Libraries of the same module violating the same rule share an issue.
+			violations: []violation{aurora, auroraSub, foo},
+			wantOpen: []string{
+				"LEGAL-License policy violation: example.com/foo (disallowed_types=unknown)",
+				"LEGAL-License policy violation: github.com/logrusorgru/aurora (disallowed_types=forbidden)",
+			},
+		},
+		{
+			// Open issues aren't duplicated, and resolved ones are closed.
+			violations: []violation{aurora},
+			wantOpen: []string{
+				"LEGAL-License policy violation: github.com/logrusorgru/aurora (disallowed_types=forbidden)",
+			},
+			wantClosed: []string{
+				"LEGAL-License policy violation: example.com/foo (disallowed_types=unknown)",
+			},
+		},
+	} {
+		fake.closed = nil
+		if err := syncJiraIssues(context.Background(), jira, "example.com/app", run.violations); err != nil {
+			t.Fatalf("syncJiraIssues() = %v, want nil", err)
+		}
+		var open []string
+		for key := range fake.issues {
+			open = append(open, key)
+		}
+		sort.Strings(open)
+		if diff := cmp.Diff(run.wantOpen, open); diff != "" {
+			t.Errorf("open issues diff (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff(run.wantClosed, fake.closed); diff != "" {
+			t.Errorf("closed issues diff (-want +got):\n%s", diff)
+		}
+		for key, labels := range fake.issues {
+			if len(labels) != 3 || labels[1] != scopeLabel {
+				t.Errorf("issue %s labels = %v, want go-licenses, %s and the violation label", key, labels, scopeLabel)
+			}
+		}
+	}
+}
+
+func TestJQLQuote(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want string
+	}{
+		{s: "LEGAL", want: `"LEGAL"`},
+		{s: `say "hi"`, want: `"say \"hi\""`},
+		{s: `C:\dir`, want: `"C:\\dir"`},
+		{s: "a\nb", want: `"a\nb"`},
+		{s: "é", want: `"é"`},
+	} {
+		if got := jqlQuote(test.s); got != test.want {
+			t.Errorf("jqlQuote(%q) = %s, want %s", test.s, got, test.want)
+		}
+	}
+}
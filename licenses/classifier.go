@@ -99,3 +99,23 @@ func (c *googleClassifier) Identify(licensePath string) (string, Type, error) {
 	licenseName := matches[0].Name
 	return licenseName, Type(licenseclassifier.LicenseType(licenseName)), nil
 }
+
+// nonSPDXLicenses are the licenses identified by the classifier whose names aren't SPDX license IDs.
+var nonSPDXLicenses = map[string]bool{
+	licenseclassifier.BCL:              true,
+	licenseclassifier.CommonsClause:    true,
+	licenseclassifier.Facebook2Clause:  true,
+	licenseclassifier.Facebook3Clause:  true,
+	licenseclassifier.FacebookExamples: true,
+	licenseclassifier.GUSTFont:         true,
+	licenseclassifier.Lil10:            true,
+	licenseclassifier.OpenVision:       true,
+	licenseclassifier.PIL:              true,
+	licenseclassifier.Python20complete: true,
+}
+
+// IsSPDXLicenseID returns whether a license name returned by the classifier is an SPDX license ID.
+// Names of licenses the classifier doesn't know, e.g. identified by other means, aren't.
+func IsSPDXLicenseID(licenseName string) bool {
+	return licenseclassifier.LicenseType(licenseName) != "" && !nonSPDXLicenses[licenseName]
+}
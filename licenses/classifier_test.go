@@ -101,3 +101,23 @@ func TestIdentify(t *testing.T) {
 		})
 	}
 }
+
+func TestIsSPDXLicenseID(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want bool
+	}{
+		{name: "Apache-2.0", want: true},
+		{name: "BSD-3-Clause", want: true},
+		{name: "GPL-2.0-with-classpath-exception", want: true},
+		{name: "Facebook-2-Clause", want: false},
+		{name: "Python-2.0-complete", want: false},
+		{name: "Custom-1.0", want: false},
+		{name: "Unknown", want: false},
+		{name: "", want: false},
+	} {
+		if got := IsSPDXLicenseID(test.name); got != test.want {
+			t.Errorf("IsSPDXLicenseID(%q) = %t, want %t", test.name, got, test.want)
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"go/build"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -35,8 +36,18 @@ type Library struct {
 	// Packages contains import paths for Go packages in this library.
 	// It may not be the complete set of all packages in the library.
 	Packages []string
+	// Direct is true if the library contains a package that is imported by a package
+	// of the scanned go modules, i.e. the modules of the packages passed to Libraries.
+	// Libraries of the scanned modules themselves are direct as well.
+	Direct bool
 	// Parent go module.
 	module *Module
+	// vendoredFrom is the go module a vendored library was copied from. The
+	// library's files are part of the parent go module that vendors it.
+	vendoredFrom *Module
+	// name is set for libraries of non-Go code bundled in the Go packages of Packages,
+	// e.g. C/C++ sources compiled with cgo, which are covered by a license of their own.
+	name string
 }
 
 // PackagesError aggregates all Packages[].Errors into a single error.
@@ -59,10 +70,13 @@ func (e PackagesError) Error() string {
 // A library is a collection of one or more packages covered by the same license file.
 // Packages not covered by a license will be returned as individual libraries.
 // Standard library packages will be ignored.
-func Libraries(ctx context.Context, classifier Classifier, ignoredPaths []string, importPaths ...string) ([]*Library, error) {
+// The packages are loaded with the go command run in the environment env, or in the current
+// environment if env is nil, e.g. to load the packages built for another GOOS/GOARCH.
+func Libraries(ctx context.Context, classifier Classifier, ignoredPaths []string, env []string, importPaths ...string) ([]*Library, error) {
 	cfg := &packages.Config{
 		Context: ctx,
 		Mode:    packages.NeedImports | packages.NeedDeps | packages.NeedFiles | packages.NeedName | packages.NeedModule,
+		Env:     env,
 	}
 
 	rootPkgs, err := packages.Load(cfg, importPaths...)
@@ -70,8 +84,19 @@ func Libraries(ctx context.Context, classifier Classifier, ignoredPaths []string
 		return nil, err
 	}
 
+	rootModules := make(map[string]bool)
+	for _, p := range rootPkgs {
+		if p.Module != nil {
+			rootModules[p.Module.Path] = true
+		}
+	}
+
 	pkgs := map[string]*packages.Package{}
 	pkgsByLicense := make(map[string][]*packages.Package)
+	// nativeLibs contains libraries of non-Go code bundled in packages, keyed by license path.
+	nativeLibs := make(map[string]*Library)
+	// directPkgs contains the packages of the scanned modules and their imports.
+	directPkgs := make(map[string]bool)
 	pkgErrorOccurred := false
 	otherErrorOccurred := false
 	packages.Visit(rootPkgs, func(p *packages.Package) bool {
@@ -83,6 +108,12 @@ func Libraries(ctx context.Context, classifier Classifier, ignoredPaths []string
 			// No license requirements for the Go standard library.
 			return false
 		}
+		if p.Module != nil && rootModules[p.Module.Path] {
+			directPkgs[p.PkgPath] = true
+			for _, imp := range p.Imports {
+				directPkgs[imp.PkgPath] = true
+			}
+		}
 		for _, i := range ignoredPaths {
 			if strings.HasPrefix(p.PkgPath, i) {
 				// Marked to be ignored.
@@ -116,6 +147,27 @@ func Libraries(ctx context.Context, classifier Classifier, ignoredPaths []string
 		}
 		pkgs[p.PkgPath] = p
 		pkgsByLicense[licensePath] = append(pkgsByLicense[licensePath], p)
+		if len(p.OtherFiles) > 0 {
+			nativeLicenses, err := findNativeLicenses(pkgDir, classifier)
+			if err != nil {
+				klog.Errorf("Failed to find licenses of non-Go code in %s: %v", p.PkgPath, err)
+			}
+			for _, nativeLicense := range nativeLicenses {
+				if nativeLicense == licensePath {
+					continue
+				}
+				lib, ok := nativeLibs[nativeLicense]
+				if !ok {
+					lib = &Library{
+						LicensePath: nativeLicense,
+						module:      newModule(p.Module),
+						name:        nativeLibraryName(p.PkgPath, pkgDir, nativeLicense),
+					}
+					nativeLibs[nativeLicense] = lib
+				}
+				lib.Packages = append(lib.Packages, p.PkgPath)
+			}
+		}
 		return true
 	}, nil)
 	if pkgErrorOccurred {
@@ -134,6 +186,7 @@ func Libraries(ctx context.Context, classifier Classifier, ignoredPaths []string
 			for _, p := range pkgs {
 				libraries = append(libraries, &Library{
 					Packages: []string{p.PkgPath},
+					Direct:   directPkgs[p.PkgPath],
 					module:   newModule(p.Module),
 				})
 			}
@@ -144,6 +197,9 @@ func Libraries(ctx context.Context, classifier Classifier, ignoredPaths []string
 		}
 		for _, pkg := range pkgs {
 			lib.Packages = append(lib.Packages, pkg.PkgPath)
+			if directPkgs[pkg.PkgPath] {
+				lib.Direct = true
+			}
 			if lib.module == nil && pkg.Module != nil {
 				// All the sub packages should belong to the same module.
 				lib.module = newModule(pkg.Module)
@@ -182,12 +238,24 @@ func Libraries(ctx context.Context, classifier Classifier, ignoredPaths []string
 				} else {
 					// Vendored modules should be commited in the parent module, so it counts as part of the
 					// parent module.
+					lib.vendoredFrom = lib.module
 					lib.module = newModule(parentPkg.Module)
 				}
 			}
 		}
 		libraries = append(libraries, lib)
 	}
+	for licensePath, lib := range nativeLibs {
+		if _, ok := pkgsByLicense[licensePath]; ok {
+			// The license already covers Go packages.
+			continue
+		}
+		// Packages are only known to be direct once all packages were visited.
+		for _, pkg := range lib.Packages {
+			lib.Direct = lib.Direct || directPkgs[pkg]
+		}
+		libraries = append(libraries, lib)
+	}
 	// Sort libraries to produce a stable result for snapshot diffing.
 	sort.Slice(libraries, func(i, j int) bool {
 		return libraries[i].Name() < libraries[j].Name()
@@ -195,11 +263,50 @@ func Libraries(ctx context.Context, classifier Classifier, ignoredPaths []string
 	return libraries, nil
 }
 
+// ModuleLibrary returns the library of a whole go module, e.g. a dependency listed in the build
+// info of a binary, whose packages are unknown. m.Dir must hold the module's files. The license
+// is searched for in the module's root directory. If none is found, the error is logged and the
+// library has no license path, like when m.Dir is empty because the module isn't available.
+func ModuleLibrary(m Module, classifier Classifier) *Library {
+	m.Version = strings.TrimSuffix(m.Version, "+incompatible")
+	var licensePath string
+	if m.Dir != "" {
+		var err error
+		licensePath, err = Find(m.Dir, m.Dir, classifier)
+		if err != nil {
+			klog.Errorf("Failed to find license for %s: %v", m.Path, err)
+		}
+	}
+	return &Library{
+		LicensePath: licensePath,
+		Packages:    []string{m.Path},
+		module:      &m,
+	}
+}
+
 // Name is the common prefix of the import paths for all of the packages in this library.
+// Libraries of non-Go code are named after the location of their license in the package instead.
 func (l *Library) Name() string {
+	if l.name != "" {
+		return l.name
+	}
 	return commonAncestor(l.Packages)
 }
 
+// nativeLibraryName returns the name of the library of non-Go code in package pkgPath, located in
+// pkgDir, that is covered by licensePath. It's the import path of the license's directory, or of
+// the license file itself if it's in pkgDir.
+func nativeLibraryName(pkgPath, pkgDir, licensePath string) string {
+	rel, err := filepath.Rel(pkgDir, licensePath)
+	if err != nil {
+		return pkgPath
+	}
+	if dir := filepath.Dir(rel); dir != "." {
+		rel = dir
+	}
+	return path.Join(pkgPath, filepath.ToSlash(rel))
+}
+
 func commonAncestor(paths []string) string {
 	if len(paths) == 0 {
 		return ""
@@ -274,7 +381,38 @@ func (l *Library) FileURL(ctx context.Context, filePath string) (string, error)
 	}
 	// TODO: there are still rare cases this may result in an incorrect URL.
 	// https://github.com/nwoodmsft/go-licenses/issues/73#issuecomment-1005587408
-	return remote.FileURL(relativePath), nil
+	// URLs always use forward slashes, regardless of the OS path separator.
+	return remote.FileURL(filepath.ToSlash(relativePath)), nil
+}
+
+// RelativePath returns the path of a file in this library relative to the
+// directory of its go module. The path is separated by forward slashes on all
+// operating systems.
+func (l *Library) RelativePath(filePath string) (string, error) {
+	if l == nil {
+		return "", fmt.Errorf("library is nil")
+	}
+	m := l.module
+	if m == nil {
+		return "", fmt.Errorf("getting relative path in library %s: empty go module info", l.Name())
+	}
+	if m.Dir == "" {
+		return "", fmt.Errorf("getting relative path in library %s: empty go module dir", l.Name())
+	}
+	relativePath, err := filepath.Rel(m.Dir, filePath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(relativePath), nil
+}
+
+// ModulePath returns the path of the go module containing this library.
+// For vendored libraries, it is the parent module that vendors the library.
+func (l *Library) ModulePath() string {
+	if l.module != nil {
+		return l.module.Path
+	}
+	return ""
 }
 
 func (l *Library) Version() string {
@@ -284,6 +422,12 @@ func (l *Library) Version() string {
 	return ""
 }
 
+// VendoredFrom returns the go module a vendored library was copied from,
+// or nil if the library isn't vendored.
+func (l *Library) VendoredFrom() *Module {
+	return l.vendoredFrom
+}
+
 // isStdLib returns true if this package is part of the Go standard library.
 func isStdLib(pkg *packages.Package) bool {
 	if pkg.Name == "unsafe" {
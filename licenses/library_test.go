@@ -17,6 +17,8 @@ package licenses
 import (
 	"context"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -26,14 +28,18 @@ import (
 func TestLibraries(t *testing.T) {
 	classifier := classifierStub{
 		licenseNames: map[string]string{
-			"testdata/LICENSE":          "foo",
-			"testdata/direct/LICENSE":   "foo",
-			"testdata/indirect/LICENSE": "foo",
+			"testdata/LICENSE":               "foo",
+			"testdata/direct/LICENSE":        "foo",
+			"testdata/indirect/LICENSE":      "foo",
+			"testdata/cgo/LICENSE":           "foo",
+			"testdata/cgo/deps/zlib/LICENSE": "Zlib",
 		},
 		licenseTypes: map[string]Type{
-			"testdata/LICENSE":          Notice,
-			"testdata/direct/LICENSE":   Notice,
-			"testdata/indirect/LICENSE": Notice,
+			"testdata/LICENSE":               Notice,
+			"testdata/direct/LICENSE":        Notice,
+			"testdata/indirect/LICENSE":      Notice,
+			"testdata/cgo/LICENSE":           Notice,
+			"testdata/cgo/deps/zlib/LICENSE": Notice,
 		},
 	}
 
@@ -81,13 +87,21 @@ func TestLibraries(t *testing.T) {
 				"github.com/nwoodmsft/go-licenses/licenses/testdata/indirect",
 			},
 		},
+		{
+			desc:       "Detects licenses of bundled C code",
+			importPath: "github.com/nwoodmsft/go-licenses/licenses/testdata/cgo",
+			wantLibs: []string{
+				"github.com/nwoodmsft/go-licenses/licenses/testdata/cgo",
+				"github.com/nwoodmsft/go-licenses/licenses/testdata/cgo/deps/zlib",
+			},
+		},
 	} {
 		t.Run(test.desc, func(t *testing.T) {
 			if test.goflags != "" {
 				os.Setenv("GOFLAGS", test.goflags)
 				defer os.Unsetenv("GOFLAGS")
 			}
-			gotLibs, err := Libraries(context.Background(), classifier, test.ignore, test.importPath)
+			gotLibs, err := Libraries(context.Background(), classifier, test.ignore, nil, test.importPath)
 			if err != nil {
 				t.Fatalf("Libraries(_, %q) = (_, %q), want (_, nil)", test.importPath, err)
 			}
@@ -102,6 +116,70 @@ func TestLibraries(t *testing.T) {
 	}
 }
 
+func TestLibrariesNativeDirect(t *testing.T) {
+	// The module example.invalid/app imports example.invalid/native, which bundles C code with a
+	// license of its own, from package b, and through example.invalid/dep from package a, which
+	// is visited first.
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"app/go.mod":               "module example.invalid/app\n\ngo 1.15\n\nrequire (\n\texample.invalid/dep v0.0.0\n\texample.invalid/native v0.0.0\n)\n\nreplace example.invalid/dep => ../dep\n\nreplace example.invalid/native => ../native\n",
+		"app/LICENSE":              "app license",
+		"app/a/a.go":               "package a\n\nimport _ \"example.invalid/dep\"\n",
+		"app/b/b.go":               "package b\n\nimport _ \"example.invalid/native\"\n",
+		"dep/go.mod":               "module example.invalid/dep\n\ngo 1.15\n\nrequire example.invalid/native v0.0.0\n",
+		"dep/LICENSE":              "dep license",
+		"dep/dep.go":               "package dep\n\nimport _ \"example.invalid/native\"\n",
+		"native/go.mod":            "module example.invalid/native\n\ngo 1.15\n",
+		"native/LICENSE":           "native license",
+		"native/native.go":         "package native\n\nimport \"C\"\n",
+		"native/native.c":          "int native(void) { return 0; }\n",
+		"native/deps/zlib/LICENSE": "zlib license",
+		"native/deps/zlib/zlib.c":  "",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Join(dir, "app")); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	classifier := classifierStub{
+		licenseNames: map[string]string{
+			"LICENSE":                     "MIT",
+			"../dep/LICENSE":              "MIT",
+			"../native/LICENSE":           "MIT",
+			"../native/deps/zlib/LICENSE": "Zlib",
+		},
+	}
+	env := append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "CGO_ENABLED=1")
+	libs, err := Libraries(context.Background(), classifier, nil, env, "./a", "./b")
+	if err != nil {
+		t.Fatalf("Libraries() = (_, %v), want (_, nil)", err)
+	}
+	got := make(map[string]bool)
+	for _, lib := range libs {
+		got[lib.Name()] = lib.Direct
+	}
+	want := map[string]bool{
+		"example.invalid/app":              true,
+		"example.invalid/dep":              true,
+		"example.invalid/native":           true,
+		"example.invalid/native/deps/zlib": true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Libraries() direct diff (-want +got):\n%s", diff)
+	}
+}
+
 func TestLibraryName(t *testing.T) {
 	for _, test := range []struct {
 		desc     string
@@ -141,6 +219,16 @@ func TestLibraryName(t *testing.T) {
 			},
 			wantName: "github.com/google/trillian/vendor/coreos/etcd",
 		},
+		{
+			desc: "Library of non-Go code",
+			lib: &Library{
+				Packages: []string{
+					"github.com/mattn/go-sqlite3",
+				},
+				name: "github.com/mattn/go-sqlite3/LICENSE.sqlite",
+			},
+			wantName: "github.com/mattn/go-sqlite3/LICENSE.sqlite",
+		},
 	} {
 		t.Run(test.desc, func(t *testing.T) {
 			if got, want := test.lib.Name(), test.wantName; got != want {
@@ -255,3 +343,143 @@ func TestLibraryFileURL(t *testing.T) {
 		})
 	}
 }
+
+func TestLibraryRelativePath(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		lib     *Library
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{
+			desc: "License at module root",
+			lib: &Library{
+				module: &Module{
+					Path: "k8s.io/api",
+					Dir:  "/go/modcache/k8s.io/api@v0.23.1",
+				},
+			},
+			path: "/go/modcache/k8s.io/api@v0.23.1/LICENSE",
+			want: "LICENSE",
+		},
+		{
+			desc: "License in module subdirectory",
+			lib: &Library{
+				module: &Module{
+					Path: "github.com/google/trillian",
+					Dir:  "/go/src/github.com/google/trillian",
+				},
+			},
+			path: "/go/src/github.com/google/trillian/crypto/LICENSE",
+			want: "crypto/LICENSE",
+		},
+		{
+			// On Windows, filepath.Rel returns backslash separated paths.
+			desc: "Platform separators converted to slashes",
+			lib: &Library{
+				module: &Module{
+					Path: "github.com/google/trillian",
+					Dir:  filepath.FromSlash("/go/src/github.com/google/trillian"),
+				},
+			},
+			path: filepath.FromSlash("/go/src/github.com/google/trillian/third_party/crypto/LICENSE"),
+			want: "third_party/crypto/LICENSE",
+		},
+		{
+			desc:    "Library without module",
+			lib:     &Library{},
+			path:    "/go/src/github.com/google/trillian/LICENSE",
+			wantErr: true,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := test.lib.RelativePath(test.path)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("RelativePath(%q) = (_, %q), want err? %t", test.path, err, test.wantErr)
+			} else if gotErr {
+				return
+			}
+			if got != test.want {
+				t.Fatalf("RelativePath(%q) = %q, want %q", test.path, got, test.want)
+			}
+		})
+	}
+}
+
+func TestLibraryModule(t *testing.T) {
+	for _, test := range []struct {
+		desc             string
+		lib              *Library
+		wantPath         string
+		wantVersion      string
+		wantVendoredFrom *Module
+	}{
+		{
+			desc:        "Library without module",
+			lib:         &Library{},
+			wantPath:    "",
+			wantVersion: "",
+		},
+		{
+			desc: "Library in module",
+			lib: &Library{
+				module: &Module{
+					Path:    "github.com/google/trillian",
+					Version: "v1.2.3",
+				},
+			},
+			wantPath:    "github.com/google/trillian",
+			wantVersion: "v1.2.3",
+		},
+		{
+			desc: "Vendored library",
+			lib: &Library{
+				module: &Module{
+					Path: "github.com/google/trillian",
+					Dir:  "/go/src/github.com/google/trillian",
+				},
+				vendoredFrom: &Module{
+					Path:    "github.com/coreos/etcd",
+					Version: "v3.3.10",
+				},
+			},
+			wantPath:    "github.com/google/trillian",
+			wantVersion: "",
+			wantVendoredFrom: &Module{
+				Path:    "github.com/coreos/etcd",
+				Version: "v3.3.10",
+			},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got, want := test.lib.ModulePath(), test.wantPath; got != want {
+				t.Errorf("ModulePath() = %q, want %q", got, want)
+			}
+			if got, want := test.lib.Version(), test.wantVersion; got != want {
+				t.Errorf("Version() = %q, want %q", got, want)
+			}
+			if diff := cmp.Diff(test.wantVendoredFrom, test.lib.VendoredFrom()); diff != "" {
+				t.Errorf("VendoredFrom() diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestModuleLibrary(t *testing.T) {
+	classifier := classifierStub{
+		licenseNames: map[string]string{
+			"testdata/indirect/LICENSE": "foo",
+		},
+	}
+	lib := ModuleLibrary(Module{Path: "example.com/indirect", Version: "v2.0.0+incompatible", Dir: "testdata/indirect"}, classifier)
+	if got, want := lib.Name(), "example.com/indirect"; got != want {
+		t.Errorf("Name() = %q, want %q", got, want)
+	}
+	if got, want := lib.Version(), "v2.0.0"; got != want {
+		t.Errorf("Version() = %q, want %q", got, want)
+	}
+	if got, want := lib.LicensePath, filepath.Join("testdata", "indirect", "LICENSE"); !strings.HasSuffix(got, want) {
+		t.Errorf("LicensePath = %q, want a path ending with %q", got, want)
+	}
+}
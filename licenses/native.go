@@ -0,0 +1,61 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package licenses
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// findNativeLicenses returns the license files of non-Go code bundled in dir, a package
+// directory, e.g. C/C++ sources compiled with cgo. Subdirectories are searched as well, except
+// those containing Go packages, whose licenses are found on their own. Only files identified by
+// the classifier are returned, in lexical order.
+func findNativeLicenses(dir string, classifier Classifier) ([]string, error) {
+	var found []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path == dir {
+				return nil
+			}
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" {
+				return filepath.SkipDir
+			}
+			if hasGoFiles(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !licenseRegexp.MatchString(d.Name()) {
+			return nil
+		}
+		if _, _, err := classifier.Identify(path); err == nil {
+			found = append(found, path)
+		}
+		return nil
+	})
+	return found, err
+}
+
+// hasGoFiles reports whether dir directly contains .go files.
+func hasGoFiles(dir string) bool {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	return len(matches) > 0
+}
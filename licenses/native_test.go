@@ -0,0 +1,69 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package licenses
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindNativeLicenses(t *testing.T) {
+	classifier := classifierStub{
+		licenseNames: map[string]string{
+			"testdata/cgo/LICENSE":           "foo",
+			"testdata/cgo/deps/zlib/LICENSE": "Zlib",
+		},
+	}
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := findNativeLicenses(filepath.Join(testdata, "cgo"), classifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(testdata, "cgo", "LICENSE"),
+		filepath.Join(testdata, "cgo", "deps", "zlib", "LICENSE"),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("findNativeLicenses(testdata/cgo): diff (-want +got)\n%s", diff)
+	}
+
+	// Subdirectories with Go packages are skipped.
+	got, err = findNativeLicenses(testdata, classifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("findNativeLicenses(testdata) = %q, want none", got)
+	}
+}
+
+func TestNativeLibraryName(t *testing.T) {
+	for _, test := range []struct {
+		licensePath string
+		want        string
+	}{
+		{licensePath: "/go/sqlite3/LICENSE.sqlite", want: "github.com/mattn/go-sqlite3/LICENSE.sqlite"},
+		{licensePath: "/go/sqlite3/deps/zlib/LICENSE", want: "github.com/mattn/go-sqlite3/deps/zlib"},
+	} {
+		if got := nativeLibraryName("github.com/mattn/go-sqlite3", "/go/sqlite3", test.licensePath); got != test.want {
+			t.Errorf("nativeLibraryName(%q) = %q, want %q", test.licensePath, got, test.want)
+		}
+	}
+}
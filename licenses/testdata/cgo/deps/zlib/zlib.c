@@ -0,0 +1,2 @@
+/* Stands in for bundled third party C code. */
+int zlib_version(void) { return 1; }
@@ -0,0 +1 @@
+#include "deps/zlib/zlib.c"
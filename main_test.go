@@ -0,0 +1,40 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// TestCommandFlagsNotShared checks that no two commands bind their flags to the same variable,
+// otherwise the default of one command's flag would be overwritten by the other's.
+func TestCommandFlagsNotShared(t *testing.T) {
+	// boundBy maps the address of flag variables to the first command and flag bound to them.
+	boundBy := make(map[string]string)
+	for _, cmd := range rootCmd.Commands() {
+		cmd.LocalNonPersistentFlags().VisitAll(func(flag *pflag.Flag) {
+			name := fmt.Sprintf("%s --%s", cmd.Name(), flag.Name)
+			addr := fmt.Sprintf("%p", flag.Value)
+			if other, ok := boundBy[addr]; ok {
+				t.Errorf("%s and %s are bound to the same variable", other, name)
+				return
+			}
+			boundBy[addr] = name
+		})
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/nwoodmsft/go-licenses/licenses"
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+)
+
+var (
+	noticesHelp = "Prints a single third party notices file containing the license texts of one or more Go packages and their dependencies."
+	noticesCmd  = &cobra.Command{
+		Use:   "notices <package> [package...]",
+		Short: noticesHelp,
+		Long:  noticesHelp + packageHelp,
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  noticesMain,
+	}
+
+	noticesSeparator = strings.Repeat("=", 80)
+	// defaultNoticesHeader is the template of the header written before each license text.
+	defaultNoticesHeader = noticesSeparator + `
+{{.Name}}
+Module: {{.Module}}
+Version: {{.Version}}
+License: {{.LicenseName}}
+URL: {{.LicenseURL}}
+` + noticesSeparator + "\n\n"
+
+	// noticesFormats are the supported notices formats, keyed by --format value.
+	noticesFormats = map[string]noticesFormat{
+		"text": {
+			header: defaultNoticesHeader,
+			ext:    ".txt",
+			body:   writeTextNotice,
+		},
+		"markdown": {
+			header: `## {{.Name}}
+
+* Module: {{.Module}}
+* Version: {{.Version}}
+* License: {{.LicenseName}}
+* URL: {{.LicenseURL}}
+
+`,
+			ext:  ".md",
+			body: writeMarkdownNotice,
+		},
+		"rst": {
+			header: `{{.Name}}
+{{underline .Name}}
+
+:Module: {{.Module}}
+:Version: {{.Version}}
+:License: {{.LicenseName}}
+:URL: {{.LicenseURL}}
+
+`,
+			ext:  ".rst",
+			body: writeRSTNotice,
+		},
+	}
+
+	noticesHeaderFile  string
+	noticesDedupe      bool
+	noticesFormatName  string
+	noticesOutputPath  string
+	noticesCompression string
+)
+
+// noticesFileBase is the name of the notices file in archives, without extension.
+const noticesFileBase = "THIRD_PARTY_NOTICES"
+
+// noticesFormat describes how notices are rendered in an output format.
+type noticesFormat struct {
+	// header is the default template of the header written before each license text.
+	header string
+	// ext is the extension of the notices file in archives.
+	ext string
+	// body writes a license text after the headers of the libraries it applies to.
+	body func(w io.Writer, text string) error
+}
+
+func init() {
+	noticesCmd.Flags().StringVar(&noticesOutputPath, "output", "", "File to write the notices to, e.g. "+noticesFileBase+".txt. The file is only replaced once it is complete. If it ends with .zip, .tar.gz or .tgz, an archive containing "+noticesFileBase+".txt (or .md, .rst depending on --format) and a "+manifestFile+" is written instead. (default: stdout)")
+	if err := noticesCmd.MarkFlagFilename("output"); err != nil {
+		klog.Fatal(err)
+	}
+	noticesCmd.Flags().StringVar(&noticesHeaderFile, "header_template", "", "Custom Go template file for the header written before each license text. It is executed with the same data as report templates, and may use the underline function to underline reStructuredText headings.")
+	if err := noticesCmd.MarkFlagFilename("header_template"); err != nil {
+		klog.Fatal(err)
+	}
+	noticesCmd.Flags().BoolVar(&noticesDedupe, "dedupe", false, "Write identical license texts only once, after the headers of all libraries they apply to.")
+	noticesCmd.Flags().StringVar(&noticesFormatName, "format", "text", "Format of the notices, one of: text, markdown, rst. Markdown and rst render a heading per library and collapsible license texts, for inclusion in documentation sites.")
+	noticesCmd.Flags().StringVar(&noticesCompression, "compress", "", "Compress the notices, one of: none, gzip, zstd. (default: inferred from the --output file extension .gz or .zst)")
+
+	rootCmd.AddCommand(noticesCmd)
+}
+
+func noticesMain(_ *cobra.Command, args []string) error {
+	format, ok := noticesFormats[noticesFormatName]
+	if !ok {
+		return fmt.Errorf("unknown notices format %q, must be one of: text, markdown, rst", noticesFormatName)
+	}
+	headerText := format.header
+	if noticesHeaderFile != "" {
+		content, err := os.ReadFile(noticesHeaderFile)
+		if err != nil {
+			return err
+		}
+		headerText = string(content)
+	}
+	header, err := parseNoticesHeader(headerText)
+	if err != nil {
+		return err
+	}
+
+	classifier, identifier, err := newClassifiers()
+	if err != nil {
+		return err
+	}
+
+	libs, err := licenses.Libraries(context.Background(), classifier, ignore, nil, args...)
+	if err != nil {
+		return err
+	}
+
+	var noticesData []libraryData
+	for _, lib := range libs {
+		libData, err := resolveLibrary(context.Background(), identifier, lib)
+		if err != nil {
+			return err
+		}
+		noticesData = append(noticesData, libData)
+	}
+
+	if archive := archiveFormat(noticesOutputPath); archive != "" {
+		return archiveNotices(noticesOutputPath, archive, header, format, noticesData, noticesDedupe)
+	}
+	return writeOutput(noticesOutputPath, noticesCompression, func(w io.Writer) error {
+		return writeNotices(w, header, format, noticesData, noticesDedupe)
+	})
+}
+
+// parseNoticesHeader parses a notices header template.
+func parseNoticesHeader(text string) (*template.Template, error) {
+	return template.New("header").Funcs(template.FuncMap{
+		"underline": func(s string) string {
+			return strings.Repeat("=", utf8.RuneCountInString(s))
+		},
+	}).Parse(text)
+}
+
+// archiveNotices writes an archive containing the notices file and a manifest to path.
+// dedupe is passed to writeNotices.
+func archiveNotices(path, archive string, header *template.Template, format noticesFormat, libs []libraryData, dedupe bool) error {
+	dir, err := os.MkdirTemp("", "go-licenses-notices")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	noticesFile := noticesFileBase + format.ext
+	err = writeFileAtomic(filepath.Join(dir, noticesFile), func(w io.Writer) error {
+		return writeNotices(w, header, format, libs, dedupe)
+	})
+	if err != nil {
+		return err
+	}
+	var m manifest
+	for _, lib := range libs {
+		m.Files = append(m.Files, manifestEntry{
+			Path:    noticesFile,
+			Library: lib.Name,
+			Module:  lib.Module,
+			Version: lib.Version,
+			License: lib.LicenseName,
+			URL:     lib.LicenseURL,
+		})
+	}
+	if err := m.write(dir); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return writeArchive(w, archive, dir)
+	})
+}
+
+// notice is a license text and the libraries it applies to.
+type notice struct {
+	libs []libraryData
+	text string
+}
+
+// writeNotices writes a header for each library followed by its license text.
+// If dedupe is true, libraries with identical license texts share one copy of the text,
+// which follows the headers of all those libraries.
+func writeNotices(w io.Writer, header *template.Template, format noticesFormat, libs []libraryData, dedupe bool) error {
+	var notices []*notice
+	noticesByText := make(map[string]*notice)
+	for _, lib := range libs {
+		text := "No license file found."
+		if lib.licenseFile != "" {
+			content, err := os.ReadFile(lib.licenseFile)
+			if err != nil {
+				return err
+			}
+			// Keep the indentation of the first line, which is common in license texts.
+			text = strings.TrimRight(strings.TrimLeft(string(content), "\r\n"), " \t\r\n")
+		} else {
+			klog.Warningf("No license file found for library %s, its notice will not contain a license text", lib.Name)
+		}
+		if n, ok := noticesByText[text]; ok && dedupe {
+			n.libs = append(n.libs, lib)
+			continue
+		}
+		n := &notice{libs: []libraryData{lib}, text: text}
+		notices = append(notices, n)
+		if lib.licenseFile != "" {
+			noticesByText[text] = n
+		}
+	}
+
+	for _, n := range notices {
+		for _, lib := range n.libs {
+			if err := header.Execute(w, lib); err != nil {
+				return err
+			}
+		}
+		if err := format.body(w, n.text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTextNotice(w io.Writer, text string) error {
+	_, err := fmt.Fprintf(w, "%s\n\n", text)
+	return err
+}
+
+// writeMarkdownNotice writes text as a code block in a collapsible section.
+func writeMarkdownNotice(w io.Writer, text string) error {
+	// The fence must be longer than any backtick run in the text.
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	_, err := fmt.Fprintf(w, "<details><summary>License text</summary>\n\n%stext\n%s\n%s\n\n</details>\n\n", fence, text, fence)
+	return err
+}
+
+// writeRSTNotice writes text as a literal block. Raw HTML directives make it
+// collapsible in HTML output and are ignored by other writers.
+func writeRSTNotice(w io.Writer, text string) error {
+	var b strings.Builder
+	b.WriteString(".. raw:: html\n\n   <details><summary>License text</summary>\n\n::\n\n")
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line != "" {
+			b.WriteString("   " + line)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n.. raw:: html\n\n   </details>\n\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,290 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteNotices(t *testing.T) {
+	licenseFile := filepath.Join(t.TempDir(), "LICENSE")
+	if err := os.WriteFile(licenseFile, []byte("\n   MIT License\n\nCopyright (c) Example\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	libs := []libraryData{
+		{
+			Name:        "github.com/example/mit",
+			Module:      "github.com/example/mit",
+			Version:     "v1.0.0",
+			LicenseName: "MIT",
+			LicenseURL:  "https://github.com/example/mit/blob/v1.0.0/LICENSE",
+			licenseFile: licenseFile,
+		},
+		{
+			Name:        "github.com/example/unlicensed/pkg",
+			Module:      "github.com/example/unlicensed",
+			Version:     "v0.1.0",
+			LicenseName: UNKNOWN,
+			LicenseURL:  UNKNOWN,
+		},
+	}
+
+	header := template.Must(template.New("header").Parse(defaultNoticesHeader))
+	var got strings.Builder
+	if err := writeNotices(&got, header, noticesFormats["text"], libs, false); err != nil {
+		t.Fatalf("writeNotices() = %v, want nil", err)
+	}
+
+	want := noticesSeparator + `
+github.com/example/mit
+Module: github.com/example/mit
+Version: v1.0.0
+License: MIT
+URL: https://github.com/example/mit/blob/v1.0.0/LICENSE
+` + noticesSeparator + `
+
+   MIT License
+
+Copyright (c) Example
+
+` + noticesSeparator + `
+github.com/example/unlicensed/pkg
+Module: github.com/example/unlicensed
+Version: v0.1.0
+License: Unknown
+URL: Unknown
+` + noticesSeparator + `
+
+No license file found.
+
+`
+	if diff := cmp.Diff(want, got.String()); diff != "" {
+		t.Errorf("writeNotices() diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteNoticesCustomHeader(t *testing.T) {
+	licenseFile := filepath.Join(t.TempDir(), "LICENSE")
+	if err := os.WriteFile(licenseFile, []byte("MIT License\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	libs := []libraryData{
+		{
+			Name:        "github.com/example/mit",
+			Version:     "v1.0.0",
+			LicenseName: "MIT",
+			LicenseURL:  "https://github.com/example/mit/blob/v1.0.0/LICENSE",
+			licenseFile: licenseFile,
+		},
+	}
+
+	header := template.Must(template.New("header").Parse("--- {{.Name}} {{.Version}} ({{.LicenseURL}}) ---\n"))
+	var got strings.Builder
+	if err := writeNotices(&got, header, noticesFormats["text"], libs, false); err != nil {
+		t.Fatalf("writeNotices() = %v, want nil", err)
+	}
+
+	want := "--- github.com/example/mit v1.0.0 (https://github.com/example/mit/blob/v1.0.0/LICENSE) ---\nMIT License\n\n"
+	if diff := cmp.Diff(want, got.String()); diff != "" {
+		t.Errorf("writeNotices() diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteNoticesDedupe(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a/LICENSE": "Apache License\n",
+		"b/LICENSE": "Apache License\n",
+		"c/LICENSE": "MIT License\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	libs := []libraryData{
+		{Name: "example.com/a", licenseFile: filepath.Join(dir, "a/LICENSE")},
+		{Name: "example.com/b", licenseFile: filepath.Join(dir, "b/LICENSE")},
+		{Name: "example.com/c", licenseFile: filepath.Join(dir, "c/LICENSE")},
+		{Name: "example.com/d"},
+		{Name: "example.com/e"},
+	}
+
+	header := template.Must(template.New("header").Parse("# {{.Name}}\n"))
+	var got strings.Builder
+	if err := writeNotices(&got, header, noticesFormats["text"], libs, true); err != nil {
+		t.Fatalf("writeNotices() = %v, want nil", err)
+	}
+
+	want := `# example.com/a
+# example.com/b
+Apache License
+
+# example.com/c
+MIT License
+
+# example.com/d
+No license file found.
+
+# example.com/e
+No license file found.
+
+`
+	if diff := cmp.Diff(want, got.String()); diff != "" {
+		t.Errorf("writeNotices() diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestArchiveNotices(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a/LICENSE", "b/LICENSE"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("Apache License\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	libs := []libraryData{
+		{Name: "example.com/a/pkg", Module: "example.com/a", Version: "v1.0.0", LicenseName: "Apache-2.0", licenseFile: filepath.Join(dir, "a/LICENSE")},
+		{Name: "example.com/b", Module: "example.com/b", Version: "v0.1.0", LicenseName: "Apache-2.0", licenseFile: filepath.Join(dir, "b/LICENSE")},
+	}
+
+	header := template.Must(template.New("header").Parse("# {{.Name}}\n"))
+	path := filepath.Join(t.TempDir(), "notices.zip")
+	if err := archiveNotices(path, archiveZip, header, noticesFormats["text"], libs, true); err != nil {
+		t.Fatalf("archiveNotices() = %v, want nil", err)
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(content)
+	}
+
+	wantNotices := "# example.com/a/pkg\n# example.com/b\nApache License\n\n"
+	if diff := cmp.Diff(wantNotices, files[noticesFileBase+".txt"]); diff != "" {
+		t.Errorf("%s.txt diff (-want +got):\n%s", noticesFileBase, diff)
+	}
+	var got manifest
+	if err := json.Unmarshal([]byte(files[manifestFile]), &got); err != nil {
+		t.Fatalf("parsing %s: %v", manifestFile, err)
+	}
+	want := manifest{Files: []manifestEntry{
+		{Path: noticesFileBase + ".txt", Library: "example.com/a/pkg", Module: "example.com/a", Version: "v1.0.0", License: "Apache-2.0"},
+		{Path: noticesFileBase + ".txt", Library: "example.com/b", Module: "example.com/b", Version: "v0.1.0", License: "Apache-2.0"},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("%s diff (-want +got):\n%s", manifestFile, diff)
+	}
+}
+
+func TestWriteNoticesFormats(t *testing.T) {
+	licenseFile := filepath.Join(t.TempDir(), "LICENSE")
+	if err := os.WriteFile(licenseFile, []byte("MIT License\n\n  Copyright (c) Example\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	libs := []libraryData{
+		{
+			Name:        "github.com/example/mit",
+			Module:      "github.com/example/mit",
+			Version:     "v1.0.0",
+			LicenseName: "MIT",
+			LicenseURL:  "https://github.com/example/mit/blob/v1.0.0/LICENSE",
+			licenseFile: licenseFile,
+		},
+	}
+
+	for _, test := range []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "markdown",
+			want: "## github.com/example/mit\n\n" +
+				"* Module: github.com/example/mit\n" +
+				"* Version: v1.0.0\n" +
+				"* License: MIT\n" +
+				"* URL: https://github.com/example/mit/blob/v1.0.0/LICENSE\n\n" +
+				"<details><summary>License text</summary>\n\n" +
+				"```text\nMIT License\n\n  Copyright (c) Example\n```\n\n" +
+				"</details>\n\n",
+		},
+		{
+			format: "rst",
+			want: "github.com/example/mit\n" +
+				"======================\n\n" +
+				":Module: github.com/example/mit\n" +
+				":Version: v1.0.0\n" +
+				":License: MIT\n" +
+				":URL: https://github.com/example/mit/blob/v1.0.0/LICENSE\n\n" +
+				".. raw:: html\n\n   <details><summary>License text</summary>\n\n" +
+				"::\n\n   MIT License\n\n     Copyright (c) Example\n\n" +
+				".. raw:: html\n\n   </details>\n\n",
+		},
+	} {
+		t.Run(test.format, func(t *testing.T) {
+			format := noticesFormats[test.format]
+			header, err := parseNoticesHeader(format.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got strings.Builder
+			if err := writeNotices(&got, header, format, libs, false); err != nil {
+				t.Fatalf("writeNotices() = %v, want nil", err)
+			}
+			if diff := cmp.Diff(test.want, got.String()); diff != "" {
+				t.Errorf("writeNotices() diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteMarkdownNoticeFence(t *testing.T) {
+	var got strings.Builder
+	if err := writeMarkdownNotice(&got, "Use ``` to quote code."); err != nil {
+		t.Fatal(err)
+	}
+	want := "<details><summary>License text</summary>\n\n````text\nUse ``` to quote code.\n````\n\n</details>\n\n"
+	if diff := cmp.Diff(want, got.String()); diff != "" {
+		t.Errorf("writeMarkdownNotice() diff (-want +got):\n%s", diff)
+	}
+}
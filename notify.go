@@ -0,0 +1,161 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+var (
+	// webhookURL is the URL check posts violations to.
+	webhookURL string
+	// webhookFormat is the payload format of webhook notifications, see webhookFormatJSON and webhookFormatSlack.
+	webhookFormat string
+	// webhookState is the file recording the violations already notified, so that only new
+	// violations are posted.
+	webhookState string
+)
+
+// Supported values of --webhook_format.
+const (
+	// webhookFormatJSON posts {"violations": [...]}, with the fields of violation.
+	webhookFormatJSON = "json"
+	// webhookFormatSlack posts a message for a Slack incoming webhook.
+	webhookFormatSlack = "slack"
+)
+
+// webhookTimeout is the timeout of webhook requests.
+const webhookTimeout = 30 * time.Second
+
+func init() {
+	checkCmd.Flags().StringVar(&webhookURL, "webhook_url", "", "URL to post violations to, e.g. a Slack incoming webhook")
+	checkCmd.Flags().StringVar(&webhookFormat, "webhook_format", webhookFormatJSON, "Payload format of webhook notifications, one of: json, slack")
+	checkCmd.Flags().StringVar(&webhookState, "webhook_state", "", "File recording the violations already notified, so that only new violations are posted. It is created if it doesn't exist. (default: post all violations)")
+	if err := checkCmd.MarkFlagFilename("webhook_state", "json"); err != nil {
+		klog.Fatal(err)
+	}
+}
+
+// notifyViolations posts the violations that are not in the webhook state file to the webhook,
+// then records the violations in the state file.
+func notifyViolations(ctx context.Context, client *http.Client, violations []violation) error {
+	notified, err := readWebhookState(webhookState)
+	if err != nil {
+		return err
+	}
+	var newViolations []violation
+	for _, v := range violations {
+		if !notified[v.key()] {
+			newViolations = append(newViolations, v)
+		}
+	}
+	if len(newViolations) > 0 {
+		ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+		defer cancel()
+		if err := postWebhook(ctx, client, webhookURL, webhookFormat, newViolations); err != nil {
+			return err
+		}
+	}
+	if webhookState == "" {
+		return nil
+	}
+	// Only the current violations are kept, so that a violation that was fixed and reintroduced
+	// is notified again.
+	var keys []string
+	for _, v := range violations {
+		keys = append(keys, v.key())
+	}
+	sort.Strings(keys)
+	return writeFileAtomic(webhookState, func(w io.Writer) error {
+		return writeJSON(w, keys)
+	})
+}
+
+// key identifies a violation across runs. It doesn't include the version, so that updating a
+// library without changing its license isn't a new violation.
+func (v violation) key() string {
+	return v.Library + " " + v.License + " " + v.Rule
+}
+
+func readWebhookState(path string) (map[string]bool, error) {
+	notified := make(map[string]bool)
+	if path == "" {
+		return notified, nil
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return notified, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var keys []string
+	if err := json.Unmarshal(content, &keys); err != nil {
+		return nil, fmt.Errorf("parsing webhook state %s: %w", path, err)
+	}
+	for _, key := range keys {
+		notified[key] = true
+	}
+	return notified, nil
+}
+
+// postWebhook posts violations to url in the given format.
+func postWebhook(ctx context.Context, client *http.Client, url, format string, violations []violation) error {
+	var payload interface{}
+	switch format {
+	case webhookFormatSlack:
+		var text strings.Builder
+		fmt.Fprintf(&text, "*go-licenses check* found %d new license policy violation(s):", len(violations))
+		for _, v := range violations {
+			license := v.License
+			if license == "" {
+				license = UNKNOWN
+			}
+			fmt.Fprintf(&text, "\n• `%s` (module `%s`): license %s, rule %s", v.Library, v.Module, license, v.Rule)
+		}
+		payload = map[string]string{"text": text.String()}
+	default:
+		payload = map[string][]violation{"violations": violations}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("posting to webhook: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"gopkg.in/yaml.v3"
+)
+
+// ortIDType is the type of the ORT identifiers of Go modules.
+const ortIDType = "Go"
+
+// reportTime returns the time reports are created at.
+var reportTime = time.Now
+
+// ortResult is an OSS Review Toolkit result containing only an analyzer run.
+type ortResult struct {
+	Repository ortRepository `yaml:"repository"`
+	Analyzer   ortAnalyzer   `yaml:"analyzer"`
+}
+
+type ortRepository struct {
+	VCS          ortVCS                 `yaml:"vcs"`
+	VCSProcessed ortVCS                 `yaml:"vcs_processed"`
+	Config       map[string]interface{} `yaml:"config"`
+}
+
+type ortVCS struct {
+	Type     string `yaml:"type"`
+	URL      string `yaml:"url"`
+	Revision string `yaml:"revision"`
+	Path     string `yaml:"path"`
+}
+
+type ortAnalyzer struct {
+	StartTime   time.Time              `yaml:"start_time"`
+	EndTime     time.Time              `yaml:"end_time"`
+	Environment ortEnvironment         `yaml:"environment"`
+	Config      map[string]interface{} `yaml:"config"`
+	Result      ortAnalyzerResult      `yaml:"result"`
+}
+
+type ortEnvironment struct {
+	OS           string            `yaml:"os"`
+	Processors   int               `yaml:"processors"`
+	Variables    map[string]string `yaml:"variables"`
+	ToolVersions map[string]string `yaml:"tool_versions"`
+}
+
+type ortAnalyzerResult struct {
+	Projects []ortProject           `yaml:"projects"`
+	Packages []ortPackage           `yaml:"packages"`
+	Issues   map[string]interface{} `yaml:"issues"`
+}
+
+type ortProject struct {
+	ID                 string     `yaml:"id"`
+	DefinitionFilePath string     `yaml:"definition_file_path"`
+	DeclaredLicenses   []string   `yaml:"declared_licenses"`
+	VCS                ortVCS     `yaml:"vcs"`
+	HomepageURL        string     `yaml:"homepage_url"`
+	Scopes             []ortScope `yaml:"scopes"`
+}
+
+type ortScope struct {
+	Name         string          `yaml:"name"`
+	Dependencies []ortDependency `yaml:"dependencies"`
+}
+
+type ortDependency struct {
+	ID string `yaml:"id"`
+}
+
+type ortPackage struct {
+	ID                        string               `yaml:"id"`
+	PURL                      string               `yaml:"purl"`
+	DeclaredLicenses          []string             `yaml:"declared_licenses"`
+	DeclaredLicensesProcessed ortProcessedLicenses `yaml:"declared_licenses_processed"`
+	Description               string               `yaml:"description"`
+	HomepageURL               string               `yaml:"homepage_url"`
+	BinaryArtifact            ortRemoteArtifact    `yaml:"binary_artifact"`
+	SourceArtifact            ortRemoteArtifact    `yaml:"source_artifact"`
+	VCS                       ortVCS               `yaml:"vcs"`
+}
+
+type ortProcessedLicenses struct {
+	SPDXExpression string `yaml:"spdx_expression,omitempty"`
+}
+
+type ortRemoteArtifact struct {
+	URL  string  `yaml:"url"`
+	Hash ortHash `yaml:"hash"`
+}
+
+type ortHash struct {
+	Value     string `yaml:"value"`
+	Algorithm string `yaml:"algorithm"`
+}
+
+// reportORT writes libs as the YAML result of an OSS Review Toolkit analyzer run, so that
+// go-licenses can be used as the Go analyzer in ORT pipelines. The project is the main module of
+// the go.mod file in the current directory, and each other module is a package it depends on.
+func reportORT(w io.Writer, libs []libraryData) error {
+	start := reportTime().UTC()
+	mainModule := ""
+	if content, err := os.ReadFile("go.mod"); err == nil {
+		mainModule = modfile.ModulePath(content)
+	}
+
+	project := ortProject{
+		ID:                 ortID(mainModule, ""),
+		DefinitionFilePath: "go.mod",
+		DeclaredLicenses:   []string{},
+		Scopes:             []ortScope{{Name: "main", Dependencies: []ortDependency{}}},
+	}
+	pkgsByID := make(map[string]*ortPackage)
+	var ids []string
+	for _, lib := range libs {
+		module := lib.Module
+		if module == "" {
+			module = lib.Library
+		}
+		version := lib.Version
+		if version == UNKNOWN {
+			version = ""
+		}
+		license := lib.LicenseName
+		if module == mainModule && mainModule != "" {
+			project.DeclaredLicenses = appendLicense(project.DeclaredLicenses, license)
+			continue
+		}
+		id := ortID(module, version)
+		pkg, ok := pkgsByID[id]
+		if !ok {
+			purl := "pkg:golang/" + module
+			if version != "" {
+				purl += "@" + version
+			}
+			pkg = &ortPackage{ID: id, PURL: purl, DeclaredLicenses: []string{}}
+			pkgsByID[id] = pkg
+			ids = append(ids, id)
+		}
+		pkg.DeclaredLicenses = appendLicense(pkg.DeclaredLicenses, license)
+	}
+	sort.Strings(ids)
+
+	result := ortAnalyzerResult{Projects: []ortProject{project}, Packages: []ortPackage{}, Issues: map[string]interface{}{}}
+	for _, id := range ids {
+		pkg := pkgsByID[id]
+		pkg.DeclaredLicensesProcessed.SPDXExpression = spdxAnd(pkg.DeclaredLicenses)
+		result.Packages = append(result.Packages, *pkg)
+		result.Projects[0].Scopes[0].Dependencies = append(result.Projects[0].Scopes[0].Dependencies, ortDependency{ID: id})
+	}
+
+	ort := ortResult{
+		Repository: ortRepository{Config: map[string]interface{}{}},
+		Analyzer: ortAnalyzer{
+			StartTime: start,
+			EndTime:   reportTime().UTC(),
+			Environment: ortEnvironment{
+				OS:           runtime.GOOS,
+				Processors:   runtime.NumCPU(),
+				Variables:    map[string]string{},
+				ToolVersions: map[string]string{"go": runtime.Version()},
+			},
+			Config: map[string]interface{}{"allow_dynamic_versions": false},
+			Result: result,
+		},
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(ort); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// ortID returns the ORT identifier of a Go module, e.g. Go::github.com/google/go-cmp:v0.5.9.
+func ortID(module, version string) string {
+	return ortIDType + "::" + module + ":" + version
+}
+
+// appendLicense appends license to licenses, unless it is unknown or already in licenses.
+func appendLicense(licenses []string, license string) []string {
+	if license == UNKNOWN || license == "" {
+		return licenses
+	}
+	for _, l := range licenses {
+		if l == license {
+			return licenses
+		}
+	}
+	return append(licenses, license)
+}
+
+// spdxAnd returns the SPDX expression requiring all licenses, or "" if there are none.
+func spdxAnd(licenses []string) string {
+	expr := ""
+	for i, l := range licenses {
+		if i > 0 {
+			expr += " AND "
+		}
+		expr += l
+	}
+	return expr
+}
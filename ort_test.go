@@ -0,0 +1,127 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+)
+
+func TestReportORT(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.17\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	reportTime = func() time.Time { return time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { reportTime = time.Now }()
+
+	libs := []libraryData{
+		{Library: "example.com/app", Module: "example.com/app", Version: UNKNOWN, LicenseName: "Apache-2.0"},
+		{Library: "github.com/google/go-cmp", Module: "github.com/google/go-cmp", Version: "v0.5.9", LicenseName: "BSD-3-Clause"},
+		{Library: "golang.org/x/text", Module: "golang.org/x/text", Version: "v0.3.8", LicenseName: "BSD-3-Clause"},
+		{Library: "golang.org/x/text/internal/foo", Module: "golang.org/x/text", Version: "v0.3.8", LicenseName: "MIT"},
+		{Library: "example.com/unlicensed", Module: "example.com/unlicensed", Version: "v1.0.0", LicenseName: UNKNOWN},
+	}
+	var out strings.Builder
+	if err := reportORT(&out, libs); err != nil {
+		t.Fatalf("reportORT() = %v, want nil", err)
+	}
+	var got ortResult
+	if err := yaml.Unmarshal([]byte(out.String()), &got); err != nil {
+		t.Fatalf("parsing ORT result: %v\n%s", err, out.String())
+	}
+
+	if got, want := got.Analyzer.StartTime, reportTime(); !got.Equal(want) {
+		t.Errorf("start_time = %v, want %v", got, want)
+	}
+	wantProjects := []ortProject{{
+		ID:                 "Go::example.com/app:",
+		DefinitionFilePath: "go.mod",
+		DeclaredLicenses:   []string{"Apache-2.0"},
+		Scopes: []ortScope{{
+			Name: "main",
+			Dependencies: []ortDependency{
+				{ID: "Go::example.com/unlicensed:v1.0.0"},
+				{ID: "Go::github.com/google/go-cmp:v0.5.9"},
+				{ID: "Go::golang.org/x/text:v0.3.8"},
+			},
+		}},
+	}}
+	if diff := cmp.Diff(wantProjects, got.Analyzer.Result.Projects); diff != "" {
+		t.Errorf("projects diff (-want +got):\n%s", diff)
+	}
+	wantPackages := []ortPackage{
+		{
+			ID:               "Go::example.com/unlicensed:v1.0.0",
+			PURL:             "pkg:golang/example.com/unlicensed@v1.0.0",
+			DeclaredLicenses: []string{},
+		},
+		{
+			ID:                        "Go::github.com/google/go-cmp:v0.5.9",
+			PURL:                      "pkg:golang/github.com/google/go-cmp@v0.5.9",
+			DeclaredLicenses:          []string{"BSD-3-Clause"},
+			DeclaredLicensesProcessed: ortProcessedLicenses{SPDXExpression: "BSD-3-Clause"},
+		},
+		{
+			ID:                        "Go::golang.org/x/text:v0.3.8",
+			PURL:                      "pkg:golang/golang.org/x/text@v0.3.8",
+			DeclaredLicenses:          []string{"BSD-3-Clause", "MIT"},
+			DeclaredLicensesProcessed: ortProcessedLicenses{SPDXExpression: "BSD-3-Clause AND MIT"},
+		},
+	}
+	if diff := cmp.Diff(wantPackages, got.Analyzer.Result.Packages); diff != "" {
+		t.Errorf("packages diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestReportORTVendored(t *testing.T) {
+	libs := vendoredTestLibraries(t)
+	var out strings.Builder
+	if err := reportORT(&out, libs); err != nil {
+		t.Fatalf("reportORT() = %v, want nil", err)
+	}
+	var got ortResult
+	if err := yaml.Unmarshal([]byte(out.String()), &got); err != nil {
+		t.Fatalf("parsing ORT result: %v\n%s", err, out.String())
+	}
+	// The vendored library is a package of its own, not folded into the project.
+	wantDependencies := []ortDependency{{ID: "Go::example.com/notice:v0.1.0"}}
+	if diff := cmp.Diff(wantDependencies, got.Analyzer.Result.Projects[0].Scopes[0].Dependencies); diff != "" {
+		t.Errorf("dependencies diff (-want +got):\n%s", diff)
+	}
+	wantPackages := []ortPackage{{
+		ID:                        "Go::example.com/notice:v0.1.0",
+		PURL:                      "pkg:golang/example.com/notice@v0.1.0",
+		DeclaredLicenses:          []string{"MIT"},
+		DeclaredLicensesProcessed: ortProcessedLicenses{SPDXExpression: "MIT"},
+	}}
+	if diff := cmp.Diff(wantPackages, got.Analyzer.Result.Packages); diff != "" {
+		t.Errorf("packages diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported output compression formats.
+const (
+	compressNone = "none"
+	compressGzip = "gzip"
+	compressZstd = "zstd"
+)
+
+// writeOutput calls write with stdout if path is empty, or with a file at path otherwise.
+// Files are written atomically, see writeFileAtomic.
+//
+// The output is compressed using the compression format. If compression is empty, it is
+// inferred from the extension of path.
+func writeOutput(path, compression string, write func(w io.Writer) error) error {
+	compression, err := outputCompression(path, compression)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return compress(os.Stdout, compression, write)
+	}
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return compress(w, compression, write)
+	})
+}
+
+func outputCompression(path, compression string) (string, error) {
+	switch compression {
+	case "":
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".gz":
+			return compressGzip, nil
+		case ".zst", ".zstd":
+			return compressZstd, nil
+		}
+		return compressNone, nil
+	case compressNone, compressGzip, compressZstd:
+		return compression, nil
+	default:
+		return "", fmt.Errorf("unsupported compression %q, supported: %s, %s, %s", compression, compressNone, compressGzip, compressZstd)
+	}
+}
+
+// compress calls write with a writer that compresses to w.
+func compress(w io.Writer, compression string, write func(w io.Writer) error) error {
+	var cw io.WriteCloser
+	switch compression {
+	case compressGzip:
+		cw = gzip.NewWriter(w)
+	case compressZstd:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		cw = zw
+	default:
+		return write(w)
+	}
+	if err := write(cw); err != nil {
+		cw.Close()
+		return err
+	}
+	return cw.Close()
+}
+
+// writeFileAtomic calls write with a temporary file next to path and renames the temporary
+// file to path once write succeeds. If anything fails, an existing file at path is left untouched.
+func writeFileAtomic(path string, write func(w io.Writer) error) (err error) {
+	f, err := createReplacement(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if err := write(f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// createReplacement creates a temporary file next to path, to be renamed to path once complete.
+// It has the permissions of the existing file at path, or those of a new file created with
+// os.Create, i.e. 0666 minus the umask.
+func createReplacement(path string) (*os.File, error) {
+	dir, base := filepath.Split(path)
+	for i := 0; ; i++ {
+		name := filepath.Join(dir, fmt.Sprintf(".%s.%d.%d.tmp", base, os.Getpid(), time.Now().UnixNano()+int64(i)))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) && i < 100 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil {
+			if err := f.Chmod(info.Mode().Perm()); err != nil {
+				f.Close()
+				os.Remove(name)
+				return nil, err
+			}
+		}
+		return f, nil
+	}
+}
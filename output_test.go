@@ -0,0 +1,146 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "licenses.csv")
+	if err := os.WriteFile(path, []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wantErr := errors.New("failed midway")
+	err := writeFileAtomic(path, func(w io.Writer) error {
+		if _, err := io.WriteString(w, "partial"); err != nil {
+			return err
+		}
+		return wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("writeFileAtomic() = %v, want %v", err, wantErr)
+	}
+	if got := readFile(t, path); got != "previous" {
+		t.Errorf("after failed write, file content = %q, want %q", got, "previous")
+	}
+
+	err = writeFileAtomic(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "new")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("writeFileAtomic() = %v, want nil", err)
+	}
+	if got := readFile(t, path); got != "new" {
+		t.Errorf("after successful write, file content = %q, want %q", got, "new")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary files were not cleaned up: %v", entries)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestOutputCompression(t *testing.T) {
+	for _, test := range []struct {
+		path, compression string
+		want              string
+		wantErr           bool
+	}{
+		{path: "", compression: "", want: compressNone},
+		{path: "licenses.csv", compression: "", want: compressNone},
+		{path: "licenses.csv.gz", compression: "", want: compressGzip},
+		{path: "licenses.csv.zst", compression: "", want: compressZstd},
+		{path: "licenses.csv.gz", compression: compressNone, want: compressNone},
+		{path: "", compression: compressZstd, want: compressZstd},
+		{path: "licenses.csv", compression: "brotli", wantErr: true},
+	} {
+		got, err := outputCompression(test.path, test.compression)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("outputCompression(%q, %q) = (_, %v), want error? %v", test.path, test.compression, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("outputCompression(%q, %q) = %q, want %q", test.path, test.compression, got, test.want)
+		}
+	}
+}
+
+func TestWriteFileAtomicMode(t *testing.T) {
+	dir := t.TempDir()
+	write := func(w io.Writer) error {
+		_, err := io.WriteString(w, "report")
+		return err
+	}
+
+	// New files get the same permissions as files created with os.Create.
+	created := filepath.Join(dir, "created.csv")
+	f, err := os.Create(created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	path := filepath.Join(dir, "new.csv")
+	if err := writeFileAtomic(path, write); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fileMode(t, path), fileMode(t, created); got != want {
+		t.Errorf("mode of new file = %v, want %v", got, want)
+	}
+
+	// Replaced files keep their permissions.
+	private := filepath.Join(dir, "private.csv")
+	if err := os.WriteFile(private, []byte("previous"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(private, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(private, write); err != nil {
+		t.Fatal(err)
+	}
+	if got := fileMode(t, private); runtime.GOOS != "windows" && got != 0600 {
+		t.Errorf("mode of replaced file = %v, want %v", got, os.FileMode(0600))
+	}
+}
+
+func fileMode(t *testing.T, path string) os.FileMode {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Mode().Perm()
+}
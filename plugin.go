@@ -0,0 +1,269 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nwoodmsft/go-licenses/licenses"
+	"k8s.io/klog/v2"
+)
+
+// pluginsDir is the directory plugins are discovered in.
+var pluginsDir string
+
+// Kinds of plugins. A plugin is an executable in pluginsDir named go-licenses-<kind>-<name>. It
+// receives a JSON request on stdin and writes its response to stdout. A non-zero exit status
+// indicates an error, described by stderr.
+const (
+	// pluginFormat plugins add the report format <name>. The request is the array of libraries,
+	// with the same fields as report templates plus LicenseType. The response is the report.
+	pluginFormat = "format"
+	// pluginClassifier plugins identify licenses the built-in classifier can't. The request is
+	// {"path": <license file>}, the response {"name": <license name>, "type": <license type>}.
+	// An empty name means the plugin can't identify the license either.
+	pluginClassifier = "classifier"
+	// pluginResolver plugins find the URL of license files when the built-in host resolvers
+	// can't. The request is {"module", "version", "path"}, with the file path relative to the
+	// module root, the response {"url"}. An empty URL means the plugin can't resolve it either.
+	pluginResolver = "resolver"
+)
+
+const pluginPrefix = "go-licenses-"
+
+func init() {
+	defaultDir := ""
+	if dir, err := os.UserConfigDir(); err == nil {
+		defaultDir = filepath.Join(dir, "go-licenses", "plugins")
+	}
+	rootCmd.PersistentFlags().StringVar(&pluginsDir, "plugins_dir", defaultDir, "Directory of plugin executables named go-licenses-<format|classifier|resolver>-<name>")
+}
+
+// plugins returns the paths of the plugins of the given kind, keyed by name.
+func plugins(kind string) map[string]string {
+	found := make(map[string]string)
+	if pluginsDir == "" {
+		return found
+	}
+	entries, err := os.ReadDir(pluginsDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			klog.Warningf("Reading plugins directory: %v", err)
+		}
+		return found
+	}
+	prefix := pluginPrefix + kind + "-"
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".exe")
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		found[strings.TrimPrefix(name, prefix)] = filepath.Join(pluginsDir, e.Name())
+	}
+	return found
+}
+
+// sortedPlugins returns the paths of the plugins of the given kind, sorted by name.
+func sortedPlugins(kind string) []string {
+	byName := plugins(kind)
+	var names []string
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var paths []string
+	for _, name := range names {
+		paths = append(paths, byName[name])
+	}
+	return paths
+}
+
+// runPlugin runs the plugin at path with the JSON encoding of request on stdin, and writes its
+// stdout to w.
+func runPlugin(ctx context.Context, path string, request interface{}, w io.Writer) error {
+	input, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("plugin %s: %w: %s", filepath.Base(path), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// callPlugin runs the plugin at path with request and decodes its JSON response.
+func callPlugin(ctx context.Context, path string, request, response interface{}) error {
+	var out bytes.Buffer
+	if err := runPlugin(ctx, path, request, &out); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(out.Bytes(), response); err != nil {
+		return fmt.Errorf("plugin %s: parsing response: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// pluginLibrary is a library as passed to format plugins.
+type pluginLibrary struct {
+	libraryData
+	LicenseType string
+}
+
+// pluginFormatter returns the report writer of the format plugin with the given name, or nil if
+// there is none.
+func pluginFormatter(name string) func(w io.Writer, libs []libraryData) error {
+	path, ok := plugins(pluginFormat)[name]
+	if !ok {
+		return nil
+	}
+	return func(w io.Writer, libs []libraryData) error {
+		request := []pluginLibrary{}
+		for _, lib := range libs {
+			request = append(request, pluginLibrary{lib, lib.licenseType.String()})
+		}
+		return runPlugin(context.Background(), path, request, w)
+	}
+}
+
+// licenseFileRegexp matches the names of files holding nothing but a license, unlike e.g. README
+// files that may mention one.
+var licenseFileRegexp = regexp.MustCompile(`^(?i)((UN)?LICEN(S|C)E|COPYING)`)
+
+// newClassifiers returns the classifier finding the license files of libraries, and the one
+// identifying the license files once found.
+//
+// Plugins aren't run while finding license files, which tries every file that may hold a license.
+// Instead, if there are classifier plugins, files named like license files are found even if the
+// built-in classifier can't identify them, and the plugins identify them afterwards.
+func newClassifiers() (finder, identifier licenses.Classifier, err error) {
+	classifier, err := licenses.NewClassifier(confidenceThreshold)
+	if err != nil {
+		return nil, nil, err
+	}
+	paths := sortedPlugins(pluginClassifier)
+	if len(paths) == 0 {
+		return classifier, classifier, nil
+	}
+	return licenseFileClassifier{classifier}, &pluginClassifierChain{classifier: classifier, plugins: paths}, nil
+}
+
+// licenseFileClassifier identifies licenses with classifier, and files named like license files
+// that classifier can't identify as unknown licenses.
+type licenseFileClassifier struct {
+	classifier licenses.Classifier
+}
+
+func (c licenseFileClassifier) Identify(licensePath string) (string, licenses.Type, error) {
+	name, licenseType, err := c.classifier.Identify(licensePath)
+	if err != nil && licenseFileRegexp.MatchString(filepath.Base(licensePath)) {
+		return "", licenses.Unknown, nil
+	}
+	return name, licenseType, err
+}
+
+type pluginClassifierChain struct {
+	classifier licenses.Classifier
+	plugins    []string
+}
+
+func (c *pluginClassifierChain) Identify(licensePath string) (string, licenses.Type, error) {
+	name, licenseType, err := c.classifier.Identify(licensePath)
+	if err == nil || licensePath == "" {
+		return name, licenseType, err
+	}
+	for _, path := range c.plugins {
+		var response struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		}
+		if pluginErr := callPlugin(context.Background(), path, map[string]string{"path": licensePath}, &response); pluginErr != nil {
+			return "", "", pluginErr
+		}
+		if response.Name != "" {
+			return response.Name, pluginLicenseType(response.Type), nil
+		}
+	}
+	return name, licenseType, err
+}
+
+// fileURL returns the URL of the file at filePath in lib, falling back to resolver plugins if the
+// built-in host resolvers fail.
+func fileURL(ctx context.Context, lib *licenses.Library, filePath string) (string, error) {
+	url, err := lib.FileURL(ctx, filePath)
+	if err == nil {
+		return url, nil
+	}
+	paths := sortedPlugins(pluginResolver)
+	if len(paths) == 0 {
+		return "", err
+	}
+	// The path is relative to the module containing the file. For vendored libraries, it is the
+	// parent module vendoring them, rather than the module they were vendored from.
+	relativePath, relErr := lib.RelativePath(filePath)
+	if relErr != nil {
+		return "", err
+	}
+	url, pluginErr := resolvePluginURL(ctx, paths, lib.ModulePath(), lib.Version(), relativePath)
+	if pluginErr != nil {
+		return "", pluginErr
+	}
+	if url == "" {
+		return "", err
+	}
+	return url, nil
+}
+
+// resolvePluginURL returns the URL of the file at relativePath in the given module version, as
+// resolved by the first of the resolver plugins that can, or "" if none can.
+func resolvePluginURL(ctx context.Context, plugins []string, module, version, relativePath string) (string, error) {
+	for _, path := range plugins {
+		var response struct {
+			URL string `json:"url"`
+		}
+		request := map[string]string{"module": module, "version": version, "path": relativePath}
+		if err := callPlugin(ctx, path, request, &response); err != nil {
+			return "", err
+		}
+		if response.URL != "" {
+			return response.URL, nil
+		}
+	}
+	return "", nil
+}
+
+// pluginLicenseType returns the license type named s, ignoring case, or licenses.Unknown.
+func pluginLicenseType(s string) licenses.Type {
+	for _, t := range []licenses.Type{licenses.Restricted, licenses.Reciprocal, licenses.Notice, licenses.Permissive, licenses.Unencumbered, licenses.Forbidden} {
+		if strings.EqualFold(s, string(t)) {
+			return t
+		}
+	}
+	return licenses.Unknown
+}
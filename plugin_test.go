@@ -0,0 +1,230 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nwoodmsft/go-licenses/licenses"
+)
+
+// writePlugin writes a shell script plugin to dir.
+func writePlugin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setPluginsDir(t *testing.T, dir string) {
+	t.Helper()
+	old := pluginsDir
+	pluginsDir = dir
+	t.Cleanup(func() { pluginsDir = old })
+}
+
+type fakeClassifier struct{}
+
+func (fakeClassifier) Identify(licensePath string) (string, licenses.Type, error) {
+	if strings.HasSuffix(licensePath, "MIT") {
+		return "MIT", licenses.Notice, nil
+	}
+	return "", "", os.ErrNotExist
+}
+
+func TestPluginFormatter(t *testing.T) {
+	dir := t.TempDir()
+	setPluginsDir(t, dir)
+	// Echoes the request, so the test sees what the plugin received.
+	writePlugin(t, dir, "go-licenses-format-echo", "cat")
+	writePlugin(t, dir, "go-licenses-format-fail", "echo broken >&2; exit 3")
+
+	if pluginFormatter("missing") != nil {
+		t.Error("pluginFormatter(missing) != nil")
+	}
+	libs := []libraryData{{Name: "example.com/lib", LicenseName: "MIT", licenseType: licenses.Notice}}
+	var got bytes.Buffer
+	if err := pluginFormatter("echo")(&got, libs); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"Name":"example.com/lib"`, `"LicenseName":"MIT"`, `"LicenseType":"notice"`} {
+		if !strings.Contains(got.String(), want) {
+			t.Errorf("request %s does not contain %s", got.String(), want)
+		}
+	}
+	err := pluginFormatter("fail")(&got, libs)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("failing plugin: got error %v, want error containing stderr", err)
+	}
+}
+
+func TestPluginClassifierChain(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "go-licenses-classifier-a", `grep -q CUSTOM && echo '{"name": "Custom-1.0", "type": "FORBIDDEN"}' || echo '{}'`)
+	// Runs after a, so only answers when a doesn't.
+	writePlugin(t, dir, "go-licenses-classifier-b", `echo '{"name": "Other", "type": "unknown"}'`)
+	setPluginsDir(t, dir)
+	classifier := &pluginClassifierChain{classifier: fakeClassifier{}, plugins: sortedPlugins(pluginClassifier)}
+
+	for _, test := range []struct {
+		path     string
+		wantName string
+		wantType licenses.Type
+	}{
+		{path: "/src/MIT", wantName: "MIT", wantType: licenses.Notice},
+		{path: "/src/CUSTOM", wantName: "Custom-1.0", wantType: licenses.Forbidden},
+		{path: "/src/LICENSE", wantName: "Other", wantType: licenses.Unknown},
+	} {
+		name, licenseType, err := classifier.Identify(test.path)
+		if err != nil {
+			t.Errorf("Identify(%q): %v", test.path, err)
+			continue
+		}
+		if name != test.wantName || licenseType != test.wantType {
+			t.Errorf("Identify(%q) = %q, %q, want %q, %q", test.path, name, licenseType, test.wantName, test.wantType)
+		}
+	}
+}
+
+func TestNewClassifiers(t *testing.T) {
+	dir := t.TempDir()
+	custom := filepath.Join(dir, "LICENSE")
+	readme := filepath.Join(dir, "README")
+	for _, path := range []string{custom, readme} {
+		if err := os.WriteFile(path, []byte("CUSTOM license terms"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pluginDir := t.TempDir()
+	setPluginsDir(t, pluginDir)
+	// Records the license files it is asked to identify.
+	calls := filepath.Join(t.TempDir(), "calls")
+	writePlugin(t, pluginDir, "go-licenses-classifier-custom", `cat >> `+calls+` && echo '{"name": "Custom-1.0", "type": "forbidden"}'`)
+
+	finder, identifier, err := newClassifiers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	licensePath, err := licenses.Find(dir, dir, finder)
+	if err != nil {
+		t.Fatalf("Find() = %v, want nil", err)
+	}
+	if licensePath != custom {
+		t.Errorf("Find() = %q, want %q", licensePath, custom)
+	}
+	if _, err := os.Stat(calls); !os.IsNotExist(err) {
+		t.Errorf("classifier plugin was run to find the license file")
+	}
+	if _, _, err := finder.Identify(readme); err == nil {
+		t.Errorf("finder.Identify(%q) = nil error, want README files to only be found when identified", readme)
+	}
+
+	name, licenseType, err := identifier.Identify(licensePath)
+	if err != nil {
+		t.Fatalf("Identify(%q) = %v, want nil", licensePath, err)
+	}
+	if name != "Custom-1.0" || licenseType != licenses.Forbidden {
+		t.Errorf("Identify(%q) = %q, %q, want %q, %q", licensePath, name, licenseType, "Custom-1.0", licenses.Forbidden)
+	}
+}
+
+func TestFileURLVendored(t *testing.T) {
+	// A module vendoring example.com/lib. Its path doesn't resolve, so that the built-in host
+	// resolvers fail and the resolver plugin is asked.
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":                         "module example.invalid/parent\n\ngo 1.15\n\nrequire example.com/lib v1.2.3\n",
+		"main.go":                        "package main\n\nimport _ \"example.com/lib\"\n\nfunc main() {}\n",
+		"LICENSE":                        "parent license",
+		"vendor/modules.txt":             "# example.com/lib v1.2.3\n## explicit\nexample.com/lib\n",
+		"vendor/example.com/lib/lib.go":  "package lib\n",
+		"vendor/example.com/lib/LICENSE": "lib license",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	env := append(os.Environ(), "GOFLAGS=-mod=vendor")
+	libs, err := licenses.Libraries(context.Background(), stubClassifier{name: "MIT", licenseType: licenses.Notice}, nil, env, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lib *licenses.Library
+	for _, l := range libs {
+		if l.Name() == "example.com/lib" {
+			lib = l
+		}
+	}
+	if lib == nil {
+		t.Fatalf("Libraries() = %v, want the vendored library example.com/lib", libs)
+	}
+
+	pluginDir := t.TempDir()
+	setPluginsDir(t, pluginDir)
+	request := filepath.Join(t.TempDir(), "request")
+	writePlugin(t, pluginDir, "go-licenses-resolver-record", `cat > `+request+` && echo '{"url": "https://mirror.example/LICENSE"}'`)
+	got, err := fileURL(context.Background(), lib, lib.LicensePath)
+	if err != nil {
+		t.Fatalf("fileURL() = %v, want nil", err)
+	}
+	if want := "https://mirror.example/LICENSE"; got != want {
+		t.Errorf("fileURL() = %q, want %q", got, want)
+	}
+	// The file path is relative to the parent module, so it is resolved in the parent module.
+	gotRequest, err := os.ReadFile(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"module":"example.invalid/parent","path":"vendor/example.com/lib/LICENSE","version":""}`; string(gotRequest) != want {
+		t.Errorf("resolver plugin request = %s, want %s", gotRequest, want)
+	}
+}
+
+func TestResolvePluginURL(t *testing.T) {
+	dir := t.TempDir()
+	setPluginsDir(t, dir)
+	writePlugin(t, dir, "go-licenses-resolver-a", `grep -q '"module":"example.com/lib"' && echo '{"url": "https://mirror.example/lib/LICENSE"}' || echo '{}'`)
+	writePlugin(t, dir, "go-licenses-resolver-b", `echo '{"url": ""}'`)
+	plugins := sortedPlugins(pluginResolver)
+
+	got, err := resolvePluginURL(context.Background(), plugins, "example.com/lib", "v1.0.0", "LICENSE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://mirror.example/lib/LICENSE"; got != want {
+		t.Errorf("resolvePluginURL(example.com/lib) = %q, want %q", got, want)
+	}
+	got, err = resolvePluginURL(context.Background(), plugins, "example.com/other", "v1.0.0", "LICENSE")
+	if err != nil || got != "" {
+		t.Errorf("resolvePluginURL(example.com/other) = %q, %v, want no URL", got, err)
+	}
+}
@@ -17,7 +17,12 @@ package main
 import (
 	"context"
 	"encoding/csv"
+	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"text/template"
 
 	"github.com/nwoodmsft/go-licenses/licenses"
@@ -27,6 +32,14 @@ import (
 
 const (
 	UNKNOWN = "Unknown"
+
+	statusOK         = "ok"
+	statusUnresolved = "unresolved"
+	statusSkipped    = "skipped"
+
+	// Supported values of --granularity.
+	granularityLibrary = "library"
+	granularityPackage = "package"
 )
 
 var (
@@ -39,11 +52,77 @@ var (
 		RunE:  reportMain,
 	}
 
-	templateFile string
+	templateFile    string
+	onlyLicenses    []string
+	excludeLicenses []string
+	failFast        bool
+	includeSkipped  bool
+	outputPath      string
+	compression     string
+	columns         []string
+	granularity     string
+	reportFormat    string
+	mergeSBOMPath   string
+	// reportAllowedLicenses and reportDisallowedTypes are the policy of the violations recorded by
+	// --format=sqlite, as in check.
+	reportAllowedLicenses []string
+	reportDisallowedTypes []string
+
+	// reportFormats maps the formats accepted by --format to the function writing a report in that format.
+	reportFormats = map[string]func(w io.Writer, libs []libraryData) error{
+		"csv":       reportCSV,
+		"cyclonedx": reportCycloneDX,
+		"gitlab":    reportGitLab,
+		"ort":       reportORT,
+		"scancode":  reportScanCode,
+		"sqlite":    reportSQLiteStream,
+		"syft":      reportSyft,
+	}
+
+	// csvColumns maps the column names accepted by --columns to the value of that column for a library.
+	csvColumns = map[string]func(lib libraryData) string{
+		"name":          func(lib libraryData) string { return lib.Name },
+		"version":       func(lib libraryData) string { return lib.Version },
+		"url":           func(lib libraryData) string { return lib.LicenseURL },
+		"license":       func(lib libraryData) string { return lib.LicenseName },
+		"type":          func(lib libraryData) string { return lib.licenseType.String() },
+		"path":          func(lib libraryData) string { return lib.LicensePath },
+		"library":       func(lib libraryData) string { return lib.Library },
+		"module":        func(lib libraryData) string { return lib.Module },
+		"status":        func(lib libraryData) string { return lib.Status },
+		"details":       func(lib libraryData) string { return lib.Details },
+		"package_count": func(lib libraryData) string { return strconv.Itoa(len(lib.Packages)) },
+		"packages":      func(lib libraryData) string { return strings.Join(lib.Packages, " ") },
+		"dependency": func(lib libraryData) string {
+			if lib.Direct {
+				return "direct"
+			}
+			return "transitive"
+		},
+	}
 )
 
 func init() {
 	reportCmd.Flags().StringVar(&templateFile, "template", "", "Custom Go template file to use for report")
+	reportCmd.Flags().StringSliceVar(&onlyLicenses, "only_license", nil, "Only report libraries whose license name (e.g. MIT) or type (e.g. reciprocal) matches one of these values. Can be specified multiple times.")
+	reportCmd.Flags().StringSliceVar(&excludeLicenses, "exclude_license", nil, "Do not report libraries whose license name (e.g. MIT) or type (e.g. reciprocal) matches one of these values. Can be specified multiple times.")
+	reportCmd.Flags().BoolVar(&includeSkipped, "include_skipped", false, "Report libraries filtered out by --only_license or --exclude_license with status skipped and the filter in details, instead of leaving them out.")
+
+	reportCmd.Flags().StringVar(&outputPath, "output", "", "File to write the report to. The file is only replaced once the report is complete. (default: stdout)")
+	if err := reportCmd.MarkFlagFilename("output"); err != nil {
+		klog.Fatal(err)
+	}
+	reportCmd.Flags().StringVar(&compression, "compress", "", "Compress the report, one of: none, gzip, zstd. (default: inferred from the --output file extension .gz or .zst)")
+	reportCmd.Flags().StringSliceVar(&columns, "columns", []string{"name", "url", "license"}, "Columns to include in the CSV report, any of: "+strings.Join(csvColumnNames(), ", "))
+	reportCmd.Flags().StringVar(&granularity, "granularity", granularityLibrary, "Report one row per library or per Go package, one of: library, package. In package granularity, each package is reported with the license of its library.")
+	reportCmd.Flags().StringVar(&reportFormat, "format", "csv", "Report format, one of: "+strings.Join(reportFormatNames(), ", ")+", or the name of a format plugin. Can't be used in combination with --template.")
+	reportCmd.Flags().StringVar(&mergeSBOMPath, "merge_sbom", "", "CycloneDX or SPDX JSON document, e.g. of a container base image, to add the reported libraries to. The merged document is written instead of a report. Can't be used in combination with --format or --template.")
+	if err := reportCmd.MarkFlagFilename("merge_sbom", "json"); err != nil {
+		klog.Fatal(err)
+	}
+	reportCmd.Flags().StringSliceVar(&reportAllowedLicenses, "allowed_licenses", nil, "With --format=sqlite, record libraries whose license isn't one of these names as violations, as check does. Can't be used in combination with --disallowed_types.")
+	reportCmd.Flags().StringSliceVar(&reportDisallowedTypes, "disallowed_types", nil, "With --format=sqlite, record libraries with these license types as violations, as check does. Can't be used in combination with --allowed_licenses. (default: forbidden, unknown)")
+	reportCmd.Flags().BoolVar(&failFast, "fail_fast", false, "Exit with an error on the first library whose license cannot be found, identified or linked to, instead of reporting it as Unknown.")
 
 	rootCmd.AddCommand(reportCmd)
 }
@@ -53,59 +132,243 @@ type libraryData struct {
 	LicenseURL  string
 	LicenseName string
 	Version     string
+	LicensePath string
+	Direct      bool
+	// Status is "ok" if the license was resolved, "unresolved" with the reasons in Details if it
+	// wasn't, or "skipped" with the filter in Details for libraries filtered out of the report.
+	Status  string
+	Details string
+	// Module is the path of the go module containing the library.
+	Module string
+	// Library is the name of the library. It differs from Name when reporting individual packages.
+	Library  string
+	Packages []string
+
+	licenseType licenses.Type
+	// licenseFile is the absolute path of the license file.
+	licenseFile string
 }
 
-func reportMain(_ *cobra.Command, args []string) error {
-	classifier, err := licenses.NewClassifier(confidenceThreshold)
+func reportMain(cmd *cobra.Command, args []string) error {
+	writeReport, ok := reportFormats[reportFormat]
+	if !ok {
+		writeReport = pluginFormatter(reportFormat)
+		ok = writeReport != nil
+	}
+	if !ok {
+		return fmt.Errorf("unknown format %q, supported formats: %s", reportFormat, strings.Join(reportFormatNames(), ", "))
+	}
+	if templateFile != "" {
+		if cmd != nil && cmd.Flags().Changed("format") {
+			return fmt.Errorf("--template and --format can't be used at the same time")
+		}
+		writeReport = reportTemplate
+	}
+	if mergeSBOMPath != "" {
+		if templateFile != "" || (cmd != nil && cmd.Flags().Changed("format")) {
+			return fmt.Errorf("--merge_sbom can't be used in combination with --format or --template")
+		}
+		writeReport = func(w io.Writer, libs []libraryData) error {
+			return mergeSBOM(w, mergeSBOMPath, libs)
+		}
+	}
+	var policy licensePolicy
+	if reportFormat == reportFormatSQLite {
+		if sqliteDriver == "" {
+			return fmt.Errorf("--format=sqlite requires go-licenses to be built with cgo")
+		}
+		if c, err := outputCompression(outputPath, compression); outputPath == "" || err != nil || c != compressNone {
+			return fmt.Errorf("--format=sqlite requires an uncompressed --output database file")
+		}
+		var err error
+		if policy, err = newLicensePolicy(reportAllowedLicenses, reportDisallowedTypes); err != nil {
+			return err
+		}
+	}
+	for _, column := range columns {
+		if _, ok := csvColumns[column]; !ok {
+			return fmt.Errorf("unknown column %q, supported columns: %s", column, strings.Join(csvColumnNames(), ", "))
+		}
+	}
+	if granularity != granularityLibrary && granularity != granularityPackage {
+		return fmt.Errorf("unknown granularity %q, supported: %s, %s", granularity, granularityLibrary, granularityPackage)
+	}
+
+	classifier, identifier, err := newClassifiers()
 	if err != nil {
 		return err
 	}
 
-	libs, err := licenses.Libraries(context.Background(), classifier, ignore, args...)
+	libs, err := licenses.Libraries(context.Background(), classifier, ignore, nil, args...)
 	if err != nil {
 		return err
 	}
 
 	var reportData []libraryData
+	var skipped []skippedLibrary
 	for _, lib := range libs {
-		version := lib.Version()
-		if len(version) == 0 {
-			version = UNKNOWN
-		}
-		libData := libraryData{
-			Name:        lib.Name(),
-			Version:     version,
-			LicenseURL:  UNKNOWN,
-			LicenseName: UNKNOWN,
-		}
-		if lib.LicensePath != "" {
-			name, _, err := classifier.Identify(lib.LicensePath)
-			if err == nil {
-				libData.LicenseName = name
-			} else {
-				klog.Errorf("Error identifying license in %q: %v", lib.LicensePath, err)
-			}
-			url, err := lib.FileURL(context.Background(), lib.LicensePath)
-			if err == nil {
-				libData.LicenseURL = url
-			} else {
-				klog.Warningf("Error discovering license URL: %s", err)
-			}
+		libData, err := resolveLibrary(context.Background(), identifier, lib)
+		if err != nil {
+			return err
+		}
+		if len(onlyLicenses) > 0 && !matchesLicense(libData, onlyLicenses) {
+			skipped = append(skipped, skippedLibrary{libData, "license not matched by --only_license"})
+			continue
+		}
+		if matchesLicense(libData, excludeLicenses) {
+			skipped = append(skipped, skippedLibrary{libData, "license matched by --exclude_license"})
+			continue
 		}
 		reportData = append(reportData, libData)
 	}
+	if reportFormat == reportFormatSQLite {
+		// The database has its own table of packages, so granularity doesn't apply.
+		return reportSQLite(outputPath, args, policy, reportData, skipped)
+	}
+	if includeSkipped {
+		reportData = withSkipped(reportData, skipped)
+	}
+	if granularity == granularityPackage {
+		reportData = packageData(reportData)
+	}
 
-	if templateFile == "" {
-		return reportCSV(reportData)
-	} else {
-		return reportTemplate(reportData)
+	return writeOutput(outputPath, compression, func(w io.Writer) error {
+		return writeReport(w, reportData)
+	})
+}
+
+// resolveLibrary identifies the license of lib and discovers its URL. Problems are logged and
+// recorded in the Status and Details fields, unless --fail_fast is set, in which case they are
+// returned as an error.
+func resolveLibrary(ctx context.Context, classifier licenses.Classifier, lib *licenses.Library) (libraryData, error) {
+	// Vendored libraries are reported as the module they were vendored from.
+	module, version := lib.ModulePath(), lib.Version()
+	if m := lib.VendoredFrom(); m != nil {
+		module, version = m.Path, m.Version
+	}
+	if len(version) == 0 {
+		version = UNKNOWN
+	}
+	libData := libraryData{
+		Name:        lib.Name(),
+		Version:     version,
+		Direct:      lib.Direct,
+		Module:      module,
+		Library:     lib.Name(),
+		Packages:    lib.Packages,
+		LicenseURL:  UNKNOWN,
+		LicenseName: UNKNOWN,
+		licenseFile: lib.LicensePath,
+	}
+	// problems explains why the license could not be fully resolved.
+	var problems []string
+	if lib.LicensePath == "" {
+		if failFast {
+			return libraryData{}, fmt.Errorf("cannot find a license for library %s", lib.Name())
+		}
+		problems = append(problems, "cannot find a license file")
+	}
+	if lib.LicensePath != "" {
+		name, licenseType, err := classifier.Identify(lib.LicensePath)
+		if err == nil {
+			libData.LicenseName = name
+			libData.licenseType = licenseType
+		} else if failFast {
+			return libraryData{}, fmt.Errorf("identifying license in %q: %w", lib.LicensePath, err)
+		} else {
+			klog.Errorf("Error identifying license in %q: %v", lib.LicensePath, err)
+			problems = append(problems, fmt.Sprintf("identifying license: %v", err))
+		}
+		if path, err := lib.RelativePath(lib.LicensePath); err == nil {
+			// The path of vendored libraries is relative to the module they were vendored from, like
+			// their Module.
+			if m := lib.VendoredFrom(); m != nil {
+				path = strings.TrimPrefix(path, "vendor/"+m.Path+"/")
+			}
+			libData.LicensePath = path
+		} else {
+			klog.Warningf("Error finding license path relative to module: %s", err)
+		}
+		url, err := fileURL(ctx, lib, lib.LicensePath)
+		if err == nil {
+			libData.LicenseURL = url
+		} else if failFast {
+			return libraryData{}, fmt.Errorf("discovering license URL: %w", err)
+		} else {
+			klog.Warningf("Error discovering license URL: %s", err)
+			problems = append(problems, fmt.Sprintf("discovering license URL: %v", err))
+		}
+	}
+	libData.Status = statusOK
+	if len(problems) > 0 {
+		libData.Status = statusUnresolved
+		libData.Details = strings.Join(problems, "; ")
+	}
+	return libData, nil
+}
+
+// skippedLibrary is a library left out of a report, e.g. by --exclude_license.
+type skippedLibrary struct {
+	lib    libraryData
+	reason string
+}
+
+// withSkipped returns libs and the skipped libraries with status skipped and the reason in
+// Details, sorted by name.
+func withSkipped(libs []libraryData, skipped []skippedLibrary) []libraryData {
+	all := append([]libraryData(nil), libs...)
+	for _, s := range skipped {
+		lib := s.lib
+		lib.Status = statusSkipped
+		lib.Details = s.reason
+		if s.lib.Details != "" {
+			lib.Details += "; " + s.lib.Details
+		}
+		all = append(all, lib)
 	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+	return all
 }
 
-func reportCSV(libs []libraryData) error {
-	writer := csv.NewWriter(os.Stdout)
+// packageData splits libraries into one entry per package, named after the package.
+func packageData(libs []libraryData) []libraryData {
+	var pkgs []libraryData
 	for _, lib := range libs {
-		if err := writer.Write([]string{lib.Name, lib.LicenseURL, lib.LicenseName}); err != nil {
+		for _, pkg := range lib.Packages {
+			pkgData := lib
+			pkgData.Name = pkg
+			pkgData.Packages = []string{pkg}
+			pkgs = append(pkgs, pkgData)
+		}
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].Name < pkgs[j].Name
+	})
+	return pkgs
+}
+
+// matchesLicense returns true if the license name or license type of lib
+// matches any of the filters. Matching is case-insensitive.
+func matchesLicense(lib libraryData, filters []string) bool {
+	for _, filter := range filters {
+		filter = strings.TrimSpace(filter)
+		if strings.EqualFold(filter, lib.LicenseName) || strings.EqualFold(filter, lib.licenseType.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+func reportCSV(w io.Writer, libs []libraryData) error {
+	writer := csv.NewWriter(w)
+	for _, lib := range libs {
+		record := make([]string, 0, len(columns))
+		for _, column := range columns {
+			record = append(record, csvColumns[column](lib))
+		}
+		if err := writer.Write(record); err != nil {
 			return err
 		}
 	}
@@ -113,7 +376,25 @@ func reportCSV(libs []libraryData) error {
 	return writer.Error()
 }
 
-func reportTemplate(libs []libraryData) error {
+func csvColumnNames() []string {
+	var names []string
+	for name := range csvColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func reportFormatNames() []string {
+	var names []string
+	for name := range reportFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func reportTemplate(w io.Writer, libs []libraryData) error {
 	templateBytes, err := os.ReadFile(templateFile)
 	if err != nil {
 		return err
@@ -122,5 +403,5 @@ func reportTemplate(libs []libraryData) error {
 	if err != nil {
 		return err
 	}
-	return tmpl.Execute(os.Stdout, libs)
+	return tmpl.Execute(w, libs)
 }
@@ -0,0 +1,225 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nwoodmsft/go-licenses/licenses"
+)
+
+// stubClassifier identifies every license file with the same result.
+type stubClassifier struct {
+	name        string
+	licenseType licenses.Type
+	err         error
+}
+
+func (c stubClassifier) Identify(string) (string, licenses.Type, error) {
+	return c.name, c.licenseType, c.err
+}
+
+func setFailFast(t *testing.T, value bool) {
+	t.Helper()
+	old := failFast
+	failFast = value
+	t.Cleanup(func() { failFast = old })
+}
+
+func TestResolveLibraryFailFast(t *testing.T) {
+	withLicense := t.TempDir()
+	if err := os.WriteFile(filepath.Join(withLicense, "LICENSE"), []byte("MIT License"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mit := stubClassifier{name: "MIT", licenseType: licenses.Notice}
+	unidentified := stubClassifier{err: errors.New("no license matched")}
+
+	for _, test := range []struct {
+		desc string
+		dir  string
+		// classifier is used to identify the license after it was found.
+		classifier  licenses.Classifier
+		failFast    bool
+		wantErr     string
+		wantStatus  string
+		wantDetails string
+	}{
+		{
+			desc:       "Resolved",
+			dir:        withLicense,
+			classifier: mit,
+			failFast:   true,
+			wantStatus: statusOK,
+		},
+		{
+			desc:        "Missing license is recorded",
+			dir:         t.TempDir(),
+			classifier:  mit,
+			wantStatus:  statusUnresolved,
+			wantDetails: "cannot find a license file",
+		},
+		{
+			desc:       "Missing license aborts with --fail_fast",
+			dir:        t.TempDir(),
+			classifier: mit,
+			failFast:   true,
+			wantErr:    "cannot find a license for library github.com/example/lib",
+		},
+		{
+			desc:        "Unidentified license is recorded",
+			dir:         withLicense,
+			classifier:  unidentified,
+			wantStatus:  statusUnresolved,
+			wantDetails: "identifying license: no license matched",
+		},
+		{
+			desc:       "Unidentified license aborts with --fail_fast",
+			dir:        withLicense,
+			classifier: unidentified,
+			failFast:   true,
+			wantErr:    "no license matched",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			setFailFast(t, test.failFast)
+			lib := licenses.ModuleLibrary(licenses.Module{Path: "github.com/example/lib", Version: "v1.0.0", Dir: test.dir}, mit)
+			got, err := resolveLibrary(context.Background(), test.classifier, lib)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("resolveLibrary() = %v, want error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveLibrary() = %v", err)
+			}
+			if got.Status != test.wantStatus || got.Details != test.wantDetails {
+				t.Errorf("resolveLibrary() status = %q, details = %q, want %q, %q", got.Status, got.Details, test.wantStatus, test.wantDetails)
+			}
+			if test.wantStatus == statusOK && got.LicenseURL != "https://github.com/example/lib/blob/v1.0.0/LICENSE" {
+				t.Errorf("resolveLibrary() license URL = %q", got.LicenseURL)
+			}
+		})
+	}
+}
+
+// setGoFlags sets the GOFLAGS environment variable until the end of the test.
+func setGoFlags(t *testing.T, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv("GOFLAGS")
+	os.Setenv("GOFLAGS", value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv("GOFLAGS", old)
+		} else {
+			os.Unsetenv("GOFLAGS")
+		}
+	})
+}
+
+// inVendoredTestModule changes to testdata/modules/vendored06 until the end of the test, with the
+// go command loading packages from its vendor directory, which may be overridden in the
+// environment.
+func inVendoredTestModule(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("testdata/modules/vendored06"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+	setGoFlags(t, "-mod=vendor")
+}
+
+// vendoredTestLibraries returns the resolved libraries of testdata/modules/vendored06.
+func vendoredTestLibraries(t *testing.T) []libraryData {
+	t.Helper()
+	inVendoredTestModule(t)
+	classifier, identifier, err := newClassifiers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	libs, err := licenses.Libraries(context.Background(), classifier, nil, nil, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []libraryData
+	for _, lib := range libs {
+		libData, err := resolveLibrary(context.Background(), identifier, lib)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, libData)
+	}
+	return data
+}
+
+func TestReportVendored(t *testing.T) {
+	inVendoredTestModule(t)
+	output := filepath.Join(t.TempDir(), "licenses.csv")
+	oldColumns := columns
+	outputPath, columns = output, []string{"name", "module", "version", "license"}
+	t.Cleanup(func() { outputPath, columns = "", oldColumns })
+
+	if err := reportMain(nil, []string{"."}); err != nil {
+		t.Fatalf("reportMain() = %v, want nil", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Vendored libraries are reported with the module and version they were vendored from.
+	want := `example.com/notice,example.com/notice,v0.1.0,MIT
+github.com/nwoodmsft/go-licenses/testdata/modules/vendored06,github.com/nwoodmsft/go-licenses/testdata/modules/vendored06,Unknown,MIT
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("reportMain() diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithSkipped(t *testing.T) {
+	libs := []libraryData{
+		{Name: "example.com/a", Status: statusOK},
+		{Name: "example.com/c", Status: statusUnresolved, Details: "cannot find a license file"},
+	}
+	skipped := []skippedLibrary{
+		{libraryData{Name: "example.com/b", Status: statusOK}, "license matched by --exclude_license"},
+		{libraryData{Name: "example.com/d", Status: statusUnresolved, Details: "cannot find a license file"}, "license not matched by --only_license"},
+	}
+	want := []libraryData{
+		{Name: "example.com/a", Status: statusOK},
+		{Name: "example.com/b", Status: statusSkipped, Details: "license matched by --exclude_license"},
+		{Name: "example.com/c", Status: statusUnresolved, Details: "cannot find a license file"},
+		{Name: "example.com/d", Status: statusSkipped, Details: "license not matched by --only_license; cannot find a license file"},
+	}
+	if diff := cmp.Diff(want, withSkipped(libs, skipped), cmp.AllowUnexported(libraryData{})); diff != "" {
+		t.Errorf("withSkipped(): diff (-want +got)\n%s", diff)
+	}
+	if len(libs) != 2 {
+		t.Errorf("withSkipped() modified its argument: %v", libs)
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	reuseHelp = "Checks whether a directory, typically the scanned module itself, complies with the REUSE specification."
+	reuseCmd  = &cobra.Command{
+		Use:   "reuse [dir]",
+		Short: reuseHelp,
+		Long: reuseHelp + `
+
+Every file needs copyright and licensing information, as SPDX-FileCopyrightText
+and SPDX-License-Identifier comments or in a <file>.license file next to it, and
+every license used needs its text in the LICENSES directory, e.g.
+LICENSES/Apache-2.0.txt. When dir is in a git repository, only files tracked by
+git are checked. .reuse/dep5 and REUSE.toml files are not supported.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: reuseMain,
+	}
+
+	// spdxLicenseRegexp matches SPDX-License-Identifier tags, capturing the license expression
+	// without the end of a trailing comment.
+	spdxLicenseRegexp = regexp.MustCompile(`SPDX-License-Identifier:\s*(.*?)\s*(\*/|-->|"""|$)`)
+	// copyrightRegexp matches copyright notices accepted by the REUSE specification.
+	copyrightRegexp = regexp.MustCompile(`SPDX-FileCopyrightText:|(?i:copyright\s*(\(c\)|©)?\s*\d)|©`)
+	// reuseIgnoredRegexp matches file names that don't need copyright and licensing information.
+	reuseIgnoredRegexp = regexp.MustCompile(`^(LICEN[CS]E|COPYING)([-.].*)?$|\.license$|\.spdx$`)
+)
+
+// reuseLicensesDir is the directory containing the texts of the licenses used.
+const reuseLicensesDir = "LICENSES"
+
+func init() {
+	rootCmd.AddCommand(reuseCmd)
+}
+
+func reuseMain(_ *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	problems, err := reuseLint(dir)
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// reuseLint returns the violations of the REUSE specification in dir, sorted.
+func reuseLint(dir string) ([]string, error) {
+	files, err := reuseFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	// usedBy maps each license ID to the first file using it.
+	usedBy := make(map[string]string)
+	for _, name := range files {
+		base := path.Base(name)
+		if reuseIgnoredRegexp.MatchString(base) || strings.HasPrefix(name, reuseLicensesDir+"/") || strings.HasPrefix(name, ".reuse/") {
+			continue
+		}
+		content, err := reuseInfo(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		if !copyrightRegexp.Match(content) {
+			problems = append(problems, fmt.Sprintf("File %s has no copyright information", name))
+		}
+		ids, err := spdxLicenseIDs(content)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		if len(ids) == 0 {
+			problems = append(problems, fmt.Sprintf("File %s has no licensing information", name))
+		}
+		for _, id := range ids {
+			if _, ok := usedBy[id]; !ok {
+				usedBy[id] = name
+			}
+		}
+	}
+
+	texts := make(map[string]bool)
+	entries, err := os.ReadDir(filepath.Join(dir, reuseLicensesDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		id := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		texts[id] = true
+		if _, ok := usedBy[id]; !ok {
+			problems = append(problems, fmt.Sprintf("License %s/%s is not used by any file", reuseLicensesDir, e.Name()))
+		}
+	}
+	for id, name := range usedBy {
+		if !texts[id] {
+			problems = append(problems, fmt.Sprintf("License %s used by %s has no text in %s/, e.g. %s/%s.txt", id, name, reuseLicensesDir, reuseLicensesDir, id))
+		}
+	}
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// reuseFiles returns the slash separated paths relative to dir of the files to check. In a git
+// repository, these are the regular files tracked by git, otherwise all regular files except the
+// .git directory.
+func reuseFiles(dir string) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "-z")
+	cmd.Dir = dir
+	if out, err := cmd.Output(); err == nil {
+		var files []string
+		for _, name := range strings.Split(string(out), "\x00") {
+			if name == "" {
+				continue
+			}
+			// Skip files deleted in the work tree, and submodules and symbolic links, which git
+			// lists as well.
+			if info, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(name))); err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			files = append(files, name)
+		}
+		return files, nil
+	}
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
+
+// reuseInfo returns the content holding the copyright and licensing information of the file
+// name in dir: its <name>.license file if it exists, or the file itself.
+func reuseInfo(dir, name string) ([]byte, error) {
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if content, err := os.ReadFile(p + ".license"); err == nil {
+		return content, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	// Binary files must use a .license file.
+	head := content
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+	return content, nil
+}
+
+// spdxLicenseIDs returns the license and exception IDs in the SPDX-License-Identifier tags of content.
+func spdxLicenseIDs(content []byte) ([]string, error) {
+	var ids []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		m := spdxLicenseRegexp.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		expr := strings.NewReplacer("(", " ", ")", " ").Replace(m[1])
+		for _, token := range strings.Fields(expr) {
+			switch token {
+			case "AND", "OR", "WITH", "and", "or", "with":
+				continue
+			}
+			ids = append(ids, strings.TrimSuffix(token, "+"))
+		}
+	}
+	return ids, scanner.Err()
+}
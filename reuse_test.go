@@ -0,0 +1,139 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReuseLint(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"LICENSES/Apache-2.0.txt":      "Apache License",
+		"LICENSES/MIT.txt":             "MIT License",
+		"LICENSES/GPL-2.0-only.txt":    "GNU General Public License",
+		"LICENSE":                      "Apache License",
+		"main.go":                      "// SPDX-FileCopyrightText: 2022 Example Inc.\n// SPDX-License-Identifier: Apache-2.0\n\npackage main\n",
+		"style.css":                    "/* Copyright 2022 Example Inc. SPDX-License-Identifier: (Apache-2.0 OR MIT) */\n",
+		"logo.png":                     "\x89PNG\x00\x00",
+		"logo.png.license":             "SPDX-FileCopyrightText: 2022 Example Inc.\nSPDX-License-Identifier: Apache-2.0 WITH LLVM-exception\n",
+		"docs/no-copyright.md":         "<!-- SPDX-License-Identifier: Apache-2.0 -->\n",
+		"docs/no-license.md":           "Copyright 2022 Example Inc.\n",
+		"docs/upper-case.md":           "Copyright (C) 2019 Example Inc.\nSPDX-License-Identifier: MIT\n",
+		"internal/binary.bin":          "\x00\x01SPDX-License-Identifier: Apache-2.0",
+		"internal/.git-is-not-ignored": "© Example Inc.\nSPDX-License-Identifier: MIT\n",
+		".git/config":                  "[core]",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := reuseLint(dir)
+	if err != nil {
+		t.Fatalf("reuseLint() = (_, %v), want (_, nil)", err)
+	}
+	want := []string{
+		"File docs/no-copyright.md has no copyright information",
+		"File docs/no-license.md has no licensing information",
+		"File internal/binary.bin has no copyright information",
+		"File internal/binary.bin has no licensing information",
+		"License LICENSES/GPL-2.0-only.txt is not used by any file",
+		"License LLVM-exception used by logo.png has no text in LICENSES/, e.g. LICENSES/LLVM-exception.txt",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("reuseLint() diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestReuseLintGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"LICENSES/MIT.txt": "MIT License",
+		"docs/README.md":   "SPDX-FileCopyrightText: 2022 Example Inc.\nSPDX-License-Identifier: MIT\n",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Symbolic links and submodules are tracked by git, but aren't files to check.
+	if err := os.Symlink("docs", filepath.Join(dir, "docs-link")); err != nil {
+		t.Skipf("creating symbolic link: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "submodule"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "LICENSES", "docs", "docs-link"},
+		{"update-index", "--add", "--cacheinfo", "160000,0123456789abcdef0123456789abcdef01234567,submodule"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+
+	got, err := reuseLint(dir)
+	if err != nil {
+		t.Fatalf("reuseLint() = (_, %v), want (_, nil)", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("reuseLint() = %q, want no problems", got)
+	}
+}
+
+func TestSPDXLicenseIDs(t *testing.T) {
+	for _, test := range []struct {
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{"// SPDX-License-Identifier: MIT", []string{"MIT"}, false},
+		{"/* SPDX-License-Identifier: GPL-2.0-or-later WITH Classpath-exception-2.0 */", []string{"GPL-2.0-or-later", "Classpath-exception-2.0"}, false},
+		{"# SPDX-License-Identifier: (MIT OR Apache-2.0) AND LicenseRef-Proprietary", []string{"MIT", "Apache-2.0", "LicenseRef-Proprietary"}, false},
+		{"<!-- SPDX-License-Identifier: CC-BY-4.0 -->", []string{"CC-BY-4.0"}, false},
+		{"package main", nil, false},
+		// Lines longer than the scanner buffer can't be read.
+		{strings.Repeat("x", 2*1024*1024), nil, true},
+	} {
+		got, err := spdxLicenseIDs([]byte(test.content))
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("spdxLicenseIDs(%.20q) = (_, %v), want err? %t", test.content, err, test.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("spdxLicenseIDs(%q) diff (-want +got):\n%s", test.content, diff)
+		}
+	}
+}
@@ -16,17 +16,22 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/nwoodmsft/go-licenses/licenses"
 	"github.com/otiai10/copy"
 	"github.com/spf13/cobra"
+	"golang.org/x/tools/go/packages"
 	"k8s.io/klog/v2"
 )
 
@@ -40,17 +45,47 @@ var (
 		RunE:  saveMain,
 	}
 
-	noticeRegexp = regexp.MustCompile(`^NOTICE(\.(txt|md))?$`)
+	// noticeRegexp matches companion files that are saved alongside a license, because license
+	// terms may require redistributing them too, e.g. the NOTICE file of Apache-2.0.
+	noticeRegexp = regexp.MustCompile(`^(NOTICE|PATENTS|AUTHORS|CONTRIBUTORS|COPYING)(\.(txt|md))?$`)
 
-	// savePath is where the output of the command is written to.
+	// savePath is where the output of the command is written to, either a directory or an archive.
 	savePath string
 	// overwriteSavePath controls behaviour when the directory indicated by savePath already exists.
 	// If true, the directory will be replaced. If false, the command will fail.
 	overwriteSavePath bool
+	// checkSavePath controls whether the existing directory indicated by savePath is verified
+	// against the current dependencies, instead of being written.
+	checkSavePath bool
+	// archiveSource controls whether source code that license terms require to be saved is
+	// written to a zip archive, instead of being copied as a directory tree.
+	archiveSource bool
+	// saveLayout controls the directories libraries are saved to, see layoutMirror and layoutFlat.
+	saveLayout string
+	// flattenSavePath is a deprecated alias for --layout=flat.
+	flattenSavePath bool
+	// splitSavePath controls whether each package argument is saved to its own directory,
+	// containing only the libraries that package depends on.
+	splitSavePath bool
+	// saveTargets are the GOOS/GOARCH platforms to save libraries for, each to its own directory.
+	saveTargets []string
+)
+
+// sourceArchiveFile is the name of the archive source code is saved to with --source_archive.
+const sourceArchiveFile = "source.zip"
+
+// Supported values of --layout.
+const (
+	// layoutMirror saves libraries to a directory tree mirroring their path,
+	// e.g. github.com/google/go-cmp.
+	layoutMirror = "mirror"
+	// layoutFlat saves libraries to a single level of directories, named after the library path
+	// with slashes replaced by underscores, e.g. github.com_google_go-cmp.
+	layoutFlat = "flat"
 )
 
 func init() {
-	saveCmd.Flags().StringVar(&savePath, "save_path", "", "Directory into which files should be saved that are required by license terms")
+	saveCmd.Flags().StringVar(&savePath, "save_path", "", "Directory into which files should be saved that are required by license terms, along with a "+manifestFile+" describing them. If it ends with .zip, .tar.gz or .tgz, an archive is written instead.")
 	if err := saveCmd.MarkFlagRequired("save_path"); err != nil {
 		klog.Fatal(err)
 	}
@@ -59,42 +94,175 @@ func init() {
 	}
 
 	saveCmd.Flags().BoolVar(&overwriteSavePath, "force", false, "Delete the destination directory if it already exists.")
+	saveCmd.Flags().BoolVar(&checkSavePath, "check", false, "Verify that the existing save_path directory is up to date with the current dependencies instead of writing it. Fails if files are missing, extra or outdated.")
+	saveCmd.Flags().BoolVar(&archiveSource, "source_archive", false, "Save the source code of libraries with reciprocal or restricted licenses as "+sourceArchiveFile+" next to their license, instead of copying the source directory.")
+	saveCmd.Flags().StringVar(&saveLayout, "layout", layoutMirror, "Directory layout of saved libraries, one of: mirror (e.g. github.com/google/go-cmp), flat (e.g. github.com_google_go-cmp).")
+	saveCmd.Flags().BoolVar(&flattenSavePath, "flatten", false, "Save each library to a directory directly under save_path, named after the library path with slashes replaced by underscores.")
+	if err := saveCmd.Flags().MarkDeprecated("flatten", "use --layout=flat instead"); err != nil {
+		klog.Fatal(err)
+	}
+	saveCmd.Flags().BoolVar(&splitSavePath, "split", false, "Save the libraries of each package matched by the arguments to its own directory under save_path, named after the last element of the package path, e.g. one directory per binary.")
+	saveCmd.Flags().StringSliceVar(&saveTargets, "targets", nil, "GOOS/GOARCH platforms to save libraries for, e.g. linux/amd64,windows/amd64. The libraries of each platform are saved to their own directory under save_path, e.g. linux_amd64. (default: the current platform, saved directly to save_path)")
 
 	rootCmd.AddCommand(saveCmd)
 }
 
 func saveMain(_ *cobra.Command, args []string) error {
-
-	if overwriteSavePath {
-		if err := os.RemoveAll(savePath); err != nil {
-			return err
-		}
+	if flattenSavePath {
+		saveLayout = layoutFlat
+	}
+	if saveLayout != layoutMirror && saveLayout != layoutFlat {
+		return fmt.Errorf("unknown layout %q, supported: %s, %s", saveLayout, layoutMirror, layoutFlat)
 	}
 
-	classifier, err := licenses.NewClassifier(confidenceThreshold)
+	classifier, identifier, err := newClassifiers()
 	if err != nil {
 		return err
 	}
 
-	libs, err := licenses.Libraries(context.Background(), classifier, ignore, args...)
+	targets, err := splitTargets(args)
 	if err != nil {
 		return err
 	}
+	for _, t := range targets {
+		if err := t.load(classifier); err != nil {
+			return err
+		}
+	}
+
+	if checkSavePath {
+		return checkSaved(identifier, targets)
+	}
 
 	// Check that the save path doesn't exist, otherwise it'd end up with a mix of
-	// existing files and the output of this command.
+	// existing files and the output of this command. The directory is only deleted
+	// with --force once the libraries were loaded successfully.
+	format := archiveFormat(savePath)
+	if overwriteSavePath && format == "" {
+		if err := os.RemoveAll(savePath); err != nil {
+			return err
+		}
+	}
 	if d, err := os.Open(savePath); err == nil {
 		d.Close()
-		return fmt.Errorf("%s already exists", savePath)
+		if !overwriteSavePath {
+			return fmt.Errorf("%s already exists, use --force to replace it", savePath)
+		}
 	} else if !os.IsNotExist(err) {
 		return err
 	}
 
+	// Archives are assembled in a temporary directory first.
+	saveDir := savePath
+	if format != "" {
+		tempDir, err := os.MkdirTemp("", "go-licenses-save")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tempDir)
+		saveDir = tempDir
+	}
+
+	for _, t := range targets {
+		if err := saveLibraries(identifier, t.libs, filepath.Join(saveDir, t.dir)); err != nil {
+			return err
+		}
+	}
+	if format == "" {
+		return nil
+	}
+	return writeFileAtomic(savePath, func(w io.Writer) error {
+		return writeArchive(w, format, saveDir)
+	})
+}
+
+// saveTarget is a set of packages built for one platform, whose libraries are
+// saved to their own directory.
+type saveTarget struct {
+	// dir is the directory relative to save_path the libraries are saved to.
+	dir string
+	// packages are the package arguments.
+	packages []string
+	// goos and goarch are the platform the packages are built for, empty for the current platform.
+	goos, goarch string
+	libs         []*licenses.Library
+}
+
+// splitTargets returns the targets selected by --split and --targets for the package arguments.
+func splitTargets(args []string) ([]*saveTarget, error) {
+	targets := []*saveTarget{{packages: args}}
+	if splitSavePath {
+		pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName}, args...)
+		if err != nil {
+			return nil, err
+		}
+		targets = nil
+		pkgsByDir := make(map[string]string)
+		for _, pkg := range pkgs {
+			dir := path.Base(pkg.PkgPath)
+			if other, ok := pkgsByDir[dir]; ok {
+				return nil, fmt.Errorf("--split: packages %s and %s would both be saved to %s", other, pkg.PkgPath, filepath.Join(savePath, dir))
+			}
+			pkgsByDir[dir] = pkg.PkgPath
+			targets = append(targets, &saveTarget{dir: dir, packages: []string{pkg.PkgPath}})
+		}
+	}
+	if len(saveTargets) == 0 {
+		return targets, nil
+	}
+	var platformTargets []*saveTarget
+	for _, platform := range saveTargets {
+		parts := strings.Split(platform, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("--targets: %q is not of the form GOOS/GOARCH", platform)
+		}
+		for _, t := range targets {
+			platformTargets = append(platformTargets, &saveTarget{
+				dir:      path.Join(t.dir, parts[0]+"_"+parts[1]),
+				packages: t.packages,
+				goos:     parts[0],
+				goarch:   parts[1],
+			})
+		}
+	}
+	return platformTargets, nil
+}
+
+// load finds the libraries of the target's packages.
+func (t *saveTarget) load(classifier licenses.Classifier) error {
+	var env []string
+	if t.goos != "" {
+		// The go command uses the last value of variables set more than once.
+		env = append(os.Environ(), "GOOS="+t.goos, "GOARCH="+t.goarch)
+	}
+	libs, err := licenses.Libraries(context.Background(), classifier, ignore, env, t.packages...)
+	if err != nil {
+		return err
+	}
+
+	// Check that no two libraries are saved to the same directory, otherwise one library's license
+	// would silently overwrite another's.
+	libsBySaveName := make(map[string]*licenses.Library)
+	for _, lib := range libs {
+		name := libSaveName(lib)
+		if other, ok := libsBySaveName[name]; ok {
+			return fmt.Errorf("libraries %s and %s would both be saved to %s", other, lib, filepath.Join(savePath, t.dir, name))
+		}
+		libsBySaveName[name] = lib
+	}
+	t.libs = libs
+	return nil
+}
+
+// saveLibraries saves the files required by the license terms of libs to saveDir,
+// along with a manifest of the saved files.
+func saveLibraries(classifier licenses.Classifier, libs []*licenses.Library, saveDir string) error {
+	var saved manifest
 	libsWithBadLicenses := make(map[licenses.Type][]*licenses.Library)
 	for _, lib := range libs {
-		libSaveDir := filepath.Join(savePath, unvendor(lib.Name()))
+		libSaveDir := filepath.Join(saveDir, libSaveName(lib))
 		// Detect what type of license this library has and fulfill its requirements, e.g. copy license, copyright notice, source code, etc.
-		_, licenseType, err := classifier.Identify(lib.LicensePath)
+		licenseName, licenseType, err := classifier.Identify(lib.LicensePath)
 		if err != nil {
 			return err
 		}
@@ -102,7 +270,14 @@ func saveMain(_ *cobra.Command, args []string) error {
 		case licenses.Restricted, licenses.Reciprocal:
 			// Copy the entire source directory for the library.
 			libDir := filepath.Dir(lib.LicensePath)
-			if err := copySrc(libDir, libSaveDir); err != nil {
+			if archiveSource {
+				if err := copyNotices(lib.LicensePath, libSaveDir); err != nil {
+					return err
+				}
+				if err := archiveSrc(libDir, filepath.Join(libSaveDir, sourceArchiveFile)); err != nil {
+					return err
+				}
+			} else if err := copySrc(libDir, libSaveDir); err != nil {
 				return err
 			}
 		case licenses.Notice, licenses.Permissive, licenses.Unencumbered:
@@ -112,27 +287,158 @@ func saveMain(_ *cobra.Command, args []string) error {
 			}
 		default:
 			libsWithBadLicenses[licenseType] = append(libsWithBadLicenses[licenseType], lib)
+			continue
+		}
+		licenseURL, err := fileURL(context.Background(), lib, lib.LicensePath)
+		if err != nil {
+			klog.Warningf("Error discovering license URL: %s", err)
+			licenseURL = UNKNOWN
+		}
+		// Vendored libraries are listed with the module they were vendored from.
+		module, version := lib.ModulePath(), lib.Version()
+		if m := lib.VendoredFrom(); m != nil {
+			module, version = m.Path, m.Version
+		}
+		err = walkFiles(libSaveDir, func(name string, _ fs.FileInfo, _ io.Reader) error {
+			saved.Files = append(saved.Files, manifestEntry{
+				Path:    path.Join(filepath.ToSlash(libSaveName(lib)), name),
+				Library: unvendor(lib.Name()),
+				Module:  module,
+				Version: version,
+				License: licenseName,
+				URL:     licenseURL,
+			})
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 	}
 	if len(libsWithBadLicenses) > 0 {
 		return fmt.Errorf("one or more libraries have an incompatible/unknown license: %q", libsWithBadLicenses)
 	}
-	return nil
+	return saved.write(saveDir)
+}
+
+// checkSaved returns an error if the files in savePath differ from the files saveLibraries saves for targets.
+func checkSaved(classifier licenses.Classifier, targets []*saveTarget) error {
+	if archiveFormat(savePath) != "" {
+		return fmt.Errorf("--check only supports directories, but %s is an archive", savePath)
+	}
+	tempDir, err := os.MkdirTemp("", "go-licenses-save")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+	for _, t := range targets {
+		if err := saveLibraries(classifier, t.libs, filepath.Join(tempDir, t.dir)); err != nil {
+			return err
+		}
+	}
+
+	want, err := fileHashes(tempDir)
+	if err != nil {
+		return err
+	}
+	got, err := fileHashes(savePath)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", savePath, err)
+	}
+	var missing, extra, outdated []string
+	for name, wantHash := range want {
+		if gotHash, ok := got[name]; !ok {
+			missing = append(missing, name)
+		} else if gotHash != wantHash {
+			outdated = append(outdated, name)
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			extra = append(extra, name)
+		}
+	}
+	if len(missing) == 0 && len(extra) == 0 && len(outdated) == 0 {
+		return nil
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "%s is not up to date with the dependencies, run save with --force to update it:", savePath)
+	for _, files := range []struct {
+		problem string
+		names   []string
+	}{{"missing", missing}, {"extra", extra}, {"outdated", outdated}} {
+		sort.Strings(files.names)
+		for _, name := range files.names {
+			fmt.Fprintf(&msg, "\n%s: %s", files.problem, name)
+		}
+	}
+	return errors.New(msg.String())
+}
+
+// fileHashes returns the SHA-256 hashes of all regular files in dir, keyed by
+// their slash separated path relative to dir.
+func fileHashes(dir string) (map[string][sha256.Size]byte, error) {
+	hashes := make(map[string][sha256.Size]byte)
+	err := walkFiles(dir, func(name string, _ fs.FileInfo, r io.Reader) error {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		hashes[name] = sha256.Sum256(content)
+		return nil
+	})
+	return hashes, err
+}
+
+// libSaveName returns the path relative to savePath where files of lib are saved.
+func libSaveName(lib *licenses.Library) string {
+	name := unvendor(lib.Name())
+	if saveLayout == layoutFlat {
+		return strings.ReplaceAll(name, "/", "_")
+	}
+	return name
 }
 
 func copySrc(src, dest string) error {
+	if err := copy.Copy(src, dest, copySrcOptions()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func copySrcOptions() copy.Options {
 	// Skip the .git directory for copying, if it exists, since we don't want to save the user's
 	// local Git config along with the source code.
-	opt := copy.Options{
+	return copy.Options{
 		Skip: func(src string) (bool, error) {
 			return strings.HasSuffix(src, ".git"), nil
 		},
 		AddPermission: 0600,
 	}
-	if err := copy.Copy(src, dest, opt); err != nil {
+}
+
+// archiveSrc writes the source code in src to a zip archive at dest, skipping the same files as copySrc.
+func archiveSrc(src, dest string) error {
+	tempDir, err := os.MkdirTemp("", "go-licenses-src")
+	if err != nil {
 		return err
 	}
-	return nil
+	defer os.RemoveAll(tempDir)
+	// Modification times are kept, so that the archive is the same for unchanged source code.
+	opt := copySrcOptions()
+	opt.PreserveTimes = true
+	if err := copy.Copy(src, tempDir, opt); err != nil {
+		return err
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if err := writeArchive(f, archiveZip, tempDir); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
 func copyNotices(licensePath, dest string) error {
@@ -146,7 +452,7 @@ func copyNotices(licensePath, dest string) error {
 		return err
 	}
 	for _, f := range files {
-		if fName := f.Name(); !f.IsDir() && noticeRegexp.MatchString(fName) {
+		if fName := f.Name(); !f.IsDir() && fName != filepath.Base(licensePath) && noticeRegexp.MatchString(fName) {
 			if err := copy.Copy(filepath.Join(src, fName), filepath.Join(dest, fName)); err != nil {
 				// Workaround for unexpected permission error returned by io.Create (seems to be a noop as file is created succesfully and has expected content)
 				if !errors.Is(err, fs.ErrPermission) {
@@ -0,0 +1,526 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nwoodmsft/go-licenses/licenses"
+)
+
+// saveTestModule is the module the save tests run in. Its cmd/app command depends on a library
+// with a NOTICE file, and on another library when built for plan9, and the libraries of its cmd/collide command are saved to the same
+// directory with --layout=flat.
+const saveTestModule = "testdata/modules/save05"
+
+// inSaveTestModule changes the working directory to the test module dir for the duration of the
+// test, and resets the flags of the save command to their defaults afterwards.
+func inSaveTestModule(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+		savePath, overwriteSavePath, checkSavePath, archiveSource, saveLayout, flattenSavePath = "", false, false, false, layoutMirror, false
+		saveTargets = nil
+	})
+}
+
+// savedFiles returns the sorted names of the files saved to path, either a directory or a zip
+// archive, or nil if path doesn't exist.
+func savedFiles(t *testing.T, path string) []string {
+	t.Helper()
+	var names []string
+	if archiveFormat(path) == archiveZip {
+		zr, err := zip.OpenReader(path)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+	} else {
+		err := walkFiles(path, func(name string, _ fs.FileInfo, _ io.Reader) error {
+			names = append(names, name)
+			return nil
+		})
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSave(t *testing.T) {
+	for _, test := range []struct {
+		desc   string
+		args   []string
+		layout string
+		// flatten is the deprecated --flatten flag.
+		flatten bool
+		force   bool
+		// targets are the --targets platforms.
+		targets []string
+		// saveName is save_path relative to a temporary directory.
+		saveName string
+		// existing is whether save_path is an existing directory, containing stale.txt.
+		existing  bool
+		wantFiles []string
+		wantErr   bool
+	}{
+		{
+			desc:     "Mirror layout",
+			args:     []string{"./cmd/app"},
+			layout:   layoutMirror,
+			saveName: "licenses",
+			wantFiles: []string{
+				"example.com/notice/LICENSE",
+				"example.com/notice/NOTICE",
+				"github.com/nwoodmsft/go-licenses/testdata/modules/save05/cmd/app/LICENSE",
+				manifestFile,
+			},
+		},
+		{
+			desc:     "Flat layout",
+			args:     []string{"./cmd/app"},
+			layout:   layoutFlat,
+			saveName: "licenses",
+			wantFiles: []string{
+				"example.com_notice/LICENSE",
+				"example.com_notice/NOTICE",
+				"github.com_nwoodmsft_go-licenses_testdata_modules_save05_cmd_app/LICENSE",
+				manifestFile,
+			},
+		},
+		{
+			desc:     "Deprecated flatten flag",
+			args:     []string{"./cmd/app"},
+			layout:   layoutMirror,
+			flatten:  true,
+			saveName: "licenses",
+			wantFiles: []string{
+				"example.com_notice/LICENSE",
+				"example.com_notice/NOTICE",
+				"github.com_nwoodmsft_go-licenses_testdata_modules_save05_cmd_app/LICENSE",
+				manifestFile,
+			},
+		},
+		{
+			desc:     "Mirror layout of libraries with similar paths",
+			args:     []string{"./cmd/collide"},
+			layout:   layoutMirror,
+			saveName: "licenses",
+			wantFiles: []string{
+				"example.com/a/b_c/LICENSE",
+				"example.com/a_b/c/LICENSE",
+				"github.com/nwoodmsft/go-licenses/testdata/modules/save05/cmd/collide/LICENSE",
+				manifestFile,
+			},
+		},
+		{
+			desc:     "Flat layout of libraries saved to the same directory",
+			args:     []string{"./cmd/collide"},
+			layout:   layoutFlat,
+			saveName: "licenses",
+			wantErr:  true,
+		},
+		{
+			desc:     "Targets",
+			args:     []string{"./cmd/app"},
+			layout:   layoutMirror,
+			targets:  []string{"linux/amd64", "plan9/amd64"},
+			saveName: "licenses",
+			wantFiles: []string{
+				"linux_amd64/example.com/notice/LICENSE",
+				"linux_amd64/example.com/notice/NOTICE",
+				"linux_amd64/github.com/nwoodmsft/go-licenses/testdata/modules/save05/cmd/app/LICENSE",
+				"linux_amd64/" + manifestFile,
+				"plan9_amd64/example.com/a/b_c/LICENSE",
+				"plan9_amd64/example.com/notice/LICENSE",
+				"plan9_amd64/example.com/notice/NOTICE",
+				"plan9_amd64/github.com/nwoodmsft/go-licenses/testdata/modules/save05/cmd/app/LICENSE",
+				"plan9_amd64/" + manifestFile,
+			},
+		},
+		{
+			desc:     "Unknown layout",
+			args:     []string{"./cmd/app"},
+			layout:   "tree",
+			saveName: "licenses",
+			wantErr:  true,
+		},
+		{
+			desc:      "Existing directory",
+			args:      []string{"./cmd/app"},
+			layout:    layoutMirror,
+			saveName:  "licenses",
+			existing:  true,
+			wantFiles: []string{"stale.txt"},
+			wantErr:   true,
+		},
+		{
+			desc:     "Force replaces existing directory",
+			args:     []string{"./cmd/app"},
+			layout:   layoutMirror,
+			force:    true,
+			saveName: "licenses",
+			existing: true,
+			wantFiles: []string{
+				"example.com/notice/LICENSE",
+				"example.com/notice/NOTICE",
+				"github.com/nwoodmsft/go-licenses/testdata/modules/save05/cmd/app/LICENSE",
+				manifestFile,
+			},
+		},
+		{
+			desc:      "Force keeps existing directory if libraries fail to load",
+			args:      []string{"./cmd/missing"},
+			layout:    layoutMirror,
+			force:     true,
+			saveName:  "licenses",
+			existing:  true,
+			wantFiles: []string{"stale.txt"},
+			wantErr:   true,
+		},
+		{
+			desc:      "Force keeps existing directory if libraries collide",
+			args:      []string{"./cmd/collide"},
+			layout:    layoutFlat,
+			force:     true,
+			saveName:  "licenses",
+			existing:  true,
+			wantFiles: []string{"stale.txt"},
+			wantErr:   true,
+		},
+		{
+			desc:     "Archive",
+			args:     []string{"./cmd/app"},
+			layout:   layoutMirror,
+			saveName: "licenses.zip",
+			wantFiles: []string{
+				"example.com/notice/LICENSE",
+				"example.com/notice/NOTICE",
+				"github.com/nwoodmsft/go-licenses/testdata/modules/save05/cmd/app/LICENSE",
+				manifestFile,
+			},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			inSaveTestModule(t, saveTestModule)
+			savePath = filepath.Join(dir, test.saveName)
+			saveLayout, flattenSavePath, overwriteSavePath, saveTargets = test.layout, test.flatten, test.force, test.targets
+			if test.existing {
+				if err := os.Mkdir(savePath, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(savePath, "stale.txt"), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := saveMain(nil, test.args)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("saveMain(%q) = %v, want err? %t", test.args, err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.wantFiles, savedFiles(t, savePath)); diff != "" {
+				t.Errorf("saveMain(%q) saved files diff (-want +got):\n%s", test.args, diff)
+			}
+		})
+	}
+}
+
+func TestSaveManifest(t *testing.T) {
+	inSaveTestModule(t, saveTestModule)
+	savePath = filepath.Join(t.TempDir(), "licenses")
+	if err := saveMain(nil, []string{"./cmd/app"}); err != nil {
+		t.Fatalf("saveMain() = %v, want nil", err)
+	}
+	content, err := os.ReadFile(filepath.Join(savePath, manifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got manifest
+	if err := json.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+	// The library replaced by a local directory has no known URL.
+	notice := manifestEntry{
+		Library: "example.com/notice",
+		Module:  "./third_party/notice",
+		License: "MIT",
+		URL:     UNKNOWN,
+	}
+	noticeLicense, noticeNotice := notice, notice
+	noticeLicense.Path = "example.com/notice/LICENSE"
+	noticeNotice.Path = "example.com/notice/NOTICE"
+	want := manifest{Files: []manifestEntry{
+		noticeLicense,
+		noticeNotice,
+		{
+			Path:    "github.com/nwoodmsft/go-licenses/testdata/modules/save05/cmd/app/LICENSE",
+			Library: "github.com/nwoodmsft/go-licenses/testdata/modules/save05/cmd/app",
+			Module:  "github.com/nwoodmsft/go-licenses/testdata/modules/save05",
+			License: "MIT",
+			URL:     "https://github.com/nwoodmsft/go-licenses/blob/HEAD/testdata/modules/save05/LICENSE",
+		},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("%s diff (-want +got):\n%s", manifestFile, diff)
+	}
+}
+
+func TestSaveVendored(t *testing.T) {
+	inSaveTestModule(t, "testdata/modules/vendored06")
+	// The go command only loads packages from the vendor directory with -mod=vendor, which may be
+	// overridden in the environment.
+	setGoFlags(t, "-mod=vendor")
+
+	savePath = filepath.Join(t.TempDir(), "licenses")
+	if err := saveMain(nil, []string{"."}); err != nil {
+		t.Fatalf("saveMain() = %v, want nil", err)
+	}
+	// Vendored libraries are saved under their import path, and listed with the module they were
+	// vendored from.
+	wantFiles := []string{
+		"example.com/notice/LICENSE",
+		"example.com/notice/NOTICE",
+		"github.com/nwoodmsft/go-licenses/testdata/modules/vendored06/LICENSE",
+		manifestFile,
+	}
+	if diff := cmp.Diff(wantFiles, savedFiles(t, savePath)); diff != "" {
+		t.Errorf("saveMain() saved files diff (-want +got):\n%s", diff)
+	}
+	got, err := os.ReadFile(filepath.Join(savePath, manifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden, err := os.ReadFile(manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(golden), string(got)); diff != "" {
+		t.Errorf("%s does not match the golden file, diff (-golden +got):\n%s", manifestFile, diff)
+	}
+}
+
+func TestSaveCheck(t *testing.T) {
+	for _, test := range []struct {
+		desc string
+		// modify changes the saved directory before it is checked.
+		modify  func(dir string) error
+		wantErr string
+	}{
+		{
+			desc:   "Up to date",
+			modify: func(string) error { return nil },
+		},
+		{
+			desc: "Missing file",
+			modify: func(dir string) error {
+				return os.Remove(filepath.Join(dir, "example.com/notice/NOTICE"))
+			},
+			wantErr: "missing: example.com/notice/NOTICE",
+		},
+		{
+			desc: "Extra file",
+			modify: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "example.com/notice/README"), nil, 0644)
+			},
+			wantErr: "extra: example.com/notice/README",
+		},
+		{
+			desc: "Outdated file",
+			modify: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "example.com/notice/LICENSE"), []byte("outdated"), 0644)
+			},
+			wantErr: "outdated: example.com/notice/LICENSE",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			inSaveTestModule(t, saveTestModule)
+			savePath = filepath.Join(t.TempDir(), "licenses")
+			args := []string{"./cmd/app"}
+			if err := saveMain(nil, args); err != nil {
+				t.Fatalf("saveMain(%q) = %v, want nil", args, err)
+			}
+			if err := test.modify(savePath); err != nil {
+				t.Fatal(err)
+			}
+			before := savedFiles(t, savePath)
+
+			checkSavePath = true
+			err := saveMain(nil, args)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("saveMain(%q) with --check = %v, want nil", args, err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("saveMain(%q) with --check = %v, want error containing %q", args, err, test.wantErr)
+			}
+			if diff := cmp.Diff(before, savedFiles(t, savePath)); diff != "" {
+				t.Errorf("saveMain(%q) with --check changed files (-before +after):\n%s", args, diff)
+			}
+		})
+	}
+}
+
+func TestSaveSourceArchive(t *testing.T) {
+	for _, test := range []struct {
+		desc          string
+		archiveSource bool
+		wantFiles     []string
+		// wantArchived are the files in the source archive, if any.
+		wantArchived []string
+	}{
+		{
+			desc: "Source directory",
+			wantFiles: []string{
+				"example.com/notice/LICENSE",
+				"example.com/notice/NOTICE",
+				"example.com/notice/go.mod",
+				"example.com/notice/notice.go",
+				manifestFile,
+			},
+		},
+		{
+			desc:          "Source archive",
+			archiveSource: true,
+			wantFiles: []string{
+				"example.com/notice/LICENSE",
+				"example.com/notice/NOTICE",
+				"example.com/notice/" + sourceArchiveFile,
+				manifestFile,
+			},
+			wantArchived: []string{"LICENSE", "NOTICE", "go.mod", "notice.go"},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			inSaveTestModule(t, saveTestModule)
+			archiveSource = test.archiveSource
+			// Libraries with reciprocal licenses are saved with their source code.
+			classifier := stubClassifier{name: "MPL-2.0", licenseType: licenses.Reciprocal}
+			target := &saveTarget{packages: []string{"example.com/notice"}}
+			if err := target.load(classifier); err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			if err := saveLibraries(classifier, target.libs, dir); err != nil {
+				t.Fatalf("saveLibraries() = %v, want nil", err)
+			}
+			if diff := cmp.Diff(test.wantFiles, savedFiles(t, dir)); diff != "" {
+				t.Errorf("saveLibraries() saved files diff (-want +got):\n%s", diff)
+			}
+			if test.wantArchived == nil {
+				return
+			}
+			archived := savedFiles(t, filepath.Join(dir, "example.com/notice", sourceArchiveFile))
+			if diff := cmp.Diff(test.wantArchived, archived); diff != "" {
+				t.Errorf("saveLibraries() archived files diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSplitTargets(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		split   bool
+		targets []string
+		args    []string
+		want    []saveTarget
+		wantErr bool
+	}{
+		{
+			desc: "No split",
+			args: []string{"./a", "./b"},
+			want: []saveTarget{{packages: []string{"./a", "./b"}}},
+		},
+		{
+			desc:  "Split packages",
+			split: true,
+			args:  []string{".", "github.com/nwoodmsft/go-licenses/licenses"},
+			want: []saveTarget{
+				{dir: "go-licenses", packages: []string{"github.com/nwoodmsft/go-licenses"}},
+				{dir: "licenses", packages: []string{"github.com/nwoodmsft/go-licenses/licenses"}},
+			},
+		},
+		{
+			desc:    "Platforms",
+			targets: []string{"linux/amd64", "windows/arm64"},
+			args:    []string{"./a"},
+			want: []saveTarget{
+				{dir: "linux_amd64", packages: []string{"./a"}, goos: "linux", goarch: "amd64"},
+				{dir: "windows_arm64", packages: []string{"./a"}, goos: "windows", goarch: "arm64"},
+			},
+		},
+		{
+			desc:    "Split packages and platforms",
+			split:   true,
+			targets: []string{"linux/amd64"},
+			args:    []string{"github.com/nwoodmsft/go-licenses/licenses"},
+			want: []saveTarget{
+				{dir: "licenses/linux_amd64", packages: []string{"github.com/nwoodmsft/go-licenses/licenses"}, goos: "linux", goarch: "amd64"},
+			},
+		},
+		{
+			desc:    "Invalid platform",
+			targets: []string{"linux"},
+			args:    []string{"./a"},
+			wantErr: true,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			splitSavePath, saveTargets = test.split, test.targets
+			defer func() { splitSavePath, saveTargets = false, nil }()
+
+			got, err := splitTargets(test.args)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("splitTargets(%q) = (_, %v), want err? %t", test.args, err, test.wantErr)
+			} else if gotErr {
+				return
+			}
+			var gotTargets []saveTarget
+			for _, target := range got {
+				gotTargets = append(gotTargets, *target)
+			}
+			if diff := cmp.Diff(test.want, gotTargets, cmp.AllowUnexported(saveTarget{})); diff != "" {
+				t.Errorf("splitTargets(%q) diff (-want +got):\n%s", test.args, diff)
+			}
+		})
+	}
+}
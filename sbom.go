@@ -0,0 +1,360 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/nwoodmsft/go-licenses/licenses"
+)
+
+// cycloneDXSpecVersion is the version of the CycloneDX specification written by reportCycloneDX.
+const cycloneDXSpecVersion = "1.4"
+
+type cycloneDXBOM struct {
+	BOMFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Components  []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXComponent struct {
+	BOMRef   string             `json:"bom-ref"`
+	Type     string             `json:"type"`
+	Name     string             `json:"name"`
+	Version  string             `json:"version,omitempty"`
+	PURL     string             `json:"purl"`
+	Licenses []cycloneDXLicense `json:"licenses,omitempty"`
+}
+
+type cycloneDXLicense struct {
+	License cycloneDXLicenseID `json:"license"`
+}
+
+// cycloneDXLicenseID is a license identified either by its SPDX license ID or by its name.
+type cycloneDXLicenseID struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+// spdxExtractedLicense is a license that isn't on the SPDX license list, referred to by a
+// LicenseRef- ID in license expressions.
+type spdxExtractedLicense struct {
+	LicenseID     string `json:"licenseId"`
+	Name          string `json:"name"`
+	ExtractedText string `json:"extractedText"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// spdxIDRegexp matches the characters that are not allowed in SPDX element IDs.
+var spdxIDRegexp = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// uuidSuffixRegexp matches a UUID at the end of an SPDX document namespace.
+var uuidSuffixRegexp = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// newUUID returns a random UUID, identifying the documents written by mergeSBOM.
+var newUUID = func() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	// Version 4, variant RFC 4122.
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// reportCycloneDX writes libs as a CycloneDX JSON SBOM.
+func reportCycloneDX(w io.Writer, libs []libraryData) error {
+	bom := cycloneDXBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: cycloneDXSpecVersion,
+		Version:     1,
+		Components:  cycloneDXComponents(libs),
+	}
+	return writeJSON(w, bom)
+}
+
+// cycloneDXComponents returns the components of libs. Libraries with the same package URL, e.g.
+// the packages of a library in package granularity, are one component.
+func cycloneDXComponents(libs []libraryData) []cycloneDXComponent {
+	components := []cycloneDXComponent{}
+	seen := make(map[string]bool)
+	for _, lib := range libs {
+		purl := libraryPURL(lib)
+		if seen[purl] {
+			continue
+		}
+		seen[purl] = true
+		component := cycloneDXComponent{
+			BOMRef: purl,
+			Type:   "library",
+			Name:   libraryName(lib),
+			PURL:   purl,
+		}
+		if lib.Version != UNKNOWN {
+			component.Version = lib.Version
+		}
+		if lib.LicenseName != UNKNOWN && lib.LicenseName != "" {
+			// The ID must be on the SPDX license list, other licenses are identified by name.
+			license := cycloneDXLicenseID{Name: lib.LicenseName}
+			if licenses.IsSPDXLicenseID(lib.LicenseName) {
+				license = cycloneDXLicenseID{ID: lib.LicenseName}
+			}
+			if lib.LicenseURL != UNKNOWN {
+				license.URL = lib.LicenseURL
+			}
+			component.Licenses = []cycloneDXLicense{{License: license}}
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+// spdxPackages returns the SPDX packages of libs, and the licenses they refer to that aren't on the
+// SPDX license list. Libraries with the same package URL are one package.
+func spdxPackages(libs []libraryData) ([]spdxPackage, []spdxExtractedLicense) {
+	var pkgs []spdxPackage
+	var extracted []spdxExtractedLicense
+	extractedIDs := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, lib := range libs {
+		purl := libraryPURL(lib)
+		if seen[purl] {
+			continue
+		}
+		seen[purl] = true
+		license := "NOASSERTION"
+		if licenses.IsSPDXLicenseID(lib.LicenseName) {
+			license = lib.LicenseName
+		} else if lib.LicenseName != UNKNOWN && lib.LicenseName != "" && lib.licenseFile != "" {
+			// Other licenses are referred to by a LicenseRef- ID, with their text.
+			id := "LicenseRef-" + spdxIDRegexp.ReplaceAllString(lib.LicenseName, "-")
+			if text, err := os.ReadFile(lib.licenseFile); err == nil {
+				license = id
+				if !extractedIDs[id] {
+					extractedIDs[id] = true
+					extracted = append(extracted, spdxExtractedLicense{LicenseID: id, Name: lib.LicenseName, ExtractedText: string(text)})
+				}
+			}
+		}
+		pkg := spdxPackage{
+			SPDXID:           "SPDXRef-Package-go-" + spdxIDRegexp.ReplaceAllString(strings.TrimPrefix(purl, "pkg:golang/"), "-"),
+			Name:             libraryName(lib),
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: license,
+			LicenseDeclared:  license,
+			CopyrightText:    "NOASSERTION",
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  purl,
+			}},
+		}
+		if lib.Version != UNKNOWN {
+			pkg.VersionInfo = lib.Version
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, extracted
+}
+
+// libraryName returns the name of the library of lib, which is reported by package in package
+// granularity.
+func libraryName(lib libraryData) string {
+	if lib.Library != "" {
+		return lib.Library
+	}
+	return lib.Name
+}
+
+// libraryPURL returns the package URL of lib, e.g. pkg:golang/github.com/google/go-cmp@v0.5.9.
+// Libraries within a module, e.g. with their own license file, are identified by a subpath.
+func libraryPURL(lib libraryData) string {
+	name, subpath := lib.Library, ""
+	if lib.Module != "" {
+		name = lib.Module
+		subpath = strings.TrimPrefix(strings.TrimPrefix(lib.Library, lib.Module), "/")
+	}
+	purl := "pkg:golang/" + name
+	if lib.Version != UNKNOWN && lib.Version != "" {
+		purl += "@" + lib.Version
+	}
+	if subpath != "" {
+		purl += "#" + subpath
+	}
+	return purl
+}
+
+// mergeSBOM writes the CycloneDX or SPDX JSON document at path to w, with libs added to its
+// components or packages. Libraries already in the document are not added again. The merged
+// document is a new document, with a new CycloneDX serial number or SPDX document namespace.
+func mergeSBOM(w io.Writer, path string, libs []libraryData) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// The document is merged as generic JSON, to preserve fields go-licenses doesn't know about.
+	var doc map[string]interface{}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return fmt.Errorf("parsing SBOM %s: %w", path, err)
+	}
+	uuid, err := newUUID()
+	if err != nil {
+		return err
+	}
+	switch {
+	case doc["bomFormat"] == "CycloneDX":
+		components := jsonArray(doc, "components")
+		refs := existingValues(components, "bom-ref")
+		for _, c := range cycloneDXComponents(libs) {
+			if refs[c.BOMRef] {
+				continue
+			}
+			components = append(components, c)
+		}
+		doc["components"] = components
+		doc["serialNumber"] = "urn:uuid:" + uuid
+		doc["version"] = 1
+	case doc["spdxVersion"] != nil:
+		pkgs := jsonArray(doc, "packages")
+		ids := existingValues(pkgs, "SPDXID")
+		newPkgs, extracted := spdxPackages(libs)
+		var added []string
+		for _, p := range newPkgs {
+			if ids[p.SPDXID] {
+				continue
+			}
+			pkgs = append(pkgs, p)
+			added = append(added, p.SPDXID)
+		}
+		doc["packages"] = pkgs
+		if len(added) > 0 {
+			doc["relationships"] = append(jsonArray(doc, "relationships"), spdxRelationships(doc, added)...)
+		}
+		extractedLicenses := jsonArray(doc, "hasExtractedLicensingInfos")
+		licenseIDs := existingValues(extractedLicenses, "licenseId")
+		for _, l := range extracted {
+			if !licenseIDs[l.LicenseID] {
+				extractedLicenses = append(extractedLicenses, l)
+			}
+		}
+		if len(extractedLicenses) > 0 {
+			doc["hasExtractedLicensingInfos"] = extractedLicenses
+		}
+		if ns, ok := doc["documentNamespace"].(string); ok {
+			if uuidSuffixRegexp.MatchString(ns) {
+				doc["documentNamespace"] = uuidSuffixRegexp.ReplaceAllString(ns, uuid)
+			} else {
+				doc["documentNamespace"] = ns + "-" + uuid
+			}
+		}
+	default:
+		return fmt.Errorf("SBOM %s is neither a CycloneDX nor an SPDX JSON document", path)
+	}
+	return writeJSON(w, doc)
+}
+
+// spdxRelationships returns the relationships of the packages with the given IDs added to an SPDX
+// document: the packages the document describes contain them, or if it doesn't describe any, the
+// document describes them.
+func spdxRelationships(doc map[string]interface{}, ids []string) []interface{} {
+	docID, _ := doc["SPDXID"].(string)
+	if docID == "" {
+		docID = "SPDXRef-DOCUMENT"
+	}
+	var described []string
+	if elements, ok := doc["documentDescribes"].([]interface{}); ok {
+		for _, e := range elements {
+			if id, ok := e.(string); ok {
+				described = append(described, id)
+			}
+		}
+	}
+	for _, r := range jsonArray(doc, "relationships") {
+		if obj, ok := r.(map[string]interface{}); ok && obj["spdxElementId"] == docID && obj["relationshipType"] == "DESCRIBES" {
+			if id, ok := obj["relatedSpdxElement"].(string); ok {
+				described = append(described, id)
+			}
+		}
+	}
+
+	var relationships []interface{}
+	for _, id := range ids {
+		if len(described) == 0 {
+			relationships = append(relationships, spdxRelationship{SPDXElementID: docID, RelationshipType: "DESCRIBES", RelatedSPDXElement: id})
+			continue
+		}
+		for _, parent := range described {
+			relationships = append(relationships, spdxRelationship{SPDXElementID: parent, RelationshipType: "CONTAINS", RelatedSPDXElement: id})
+		}
+	}
+	return relationships
+}
+
+// jsonArray returns the array in field of the JSON object doc, or nil if there is none.
+func jsonArray(doc map[string]interface{}, field string) []interface{} {
+	elements, _ := doc[field].([]interface{})
+	return elements
+}
+
+// existingValues returns the string values of field in the JSON objects of elements.
+func existingValues(elements []interface{}, field string) map[string]bool {
+	values := make(map[string]bool)
+	for _, e := range elements {
+		if obj, ok := e.(map[string]interface{}); ok {
+			if v, ok := obj[field].(string); ok {
+				values[v] = true
+			}
+		}
+	}
+	return values
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
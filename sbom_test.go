@@ -0,0 +1,356 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// testUUID is returned by newUUID in tests.
+const testUUID = "0b8a4a7e-3b1a-4c8e-9f0e-2c5d6f7a8b9c"
+
+// stubUUID makes newUUID return testUUID until the end of the test.
+func stubUUID(t *testing.T) {
+	t.Helper()
+	newUUID = func() (string, error) { return testUUID, nil }
+	t.Cleanup(func() { newUUID = defaultNewUUID })
+}
+
+var defaultNewUUID = newUUID
+
+func TestLibraryPURL(t *testing.T) {
+	for _, test := range []struct {
+		desc string
+		lib  libraryData
+		want string
+	}{
+		{
+			desc: "Module",
+			lib:  libraryData{Library: "github.com/google/go-cmp", Module: "github.com/google/go-cmp", Version: "v0.5.9"},
+			want: "pkg:golang/github.com/google/go-cmp@v0.5.9",
+		},
+		{
+			desc: "Library in module subdirectory",
+			lib:  libraryData{Library: "golang.org/x/text/internal/foo", Module: "golang.org/x/text", Version: "v0.3.8"},
+			want: "pkg:golang/golang.org/x/text@v0.3.8#internal/foo",
+		},
+		{
+			desc: "Unknown version",
+			lib:  libraryData{Library: "example.com/app", Module: "example.com/app", Version: UNKNOWN},
+			want: "pkg:golang/example.com/app",
+		},
+		{
+			desc: "No module",
+			lib:  libraryData{Library: "example.com/gopath/lib", Version: UNKNOWN},
+			want: "pkg:golang/example.com/gopath/lib",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := libraryPURL(test.lib); got != test.want {
+				t.Errorf("libraryPURL() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeSBOM(t *testing.T) {
+	stubUUID(t)
+	libs := []libraryData{
+		{Name: "github.com/google/go-cmp", Library: "github.com/google/go-cmp", Module: "github.com/google/go-cmp", Version: "v0.5.9", LicenseName: "BSD-3-Clause", LicenseURL: UNKNOWN},
+		{Name: "example.com/app", Library: "example.com/app", Module: "example.com/app", Version: UNKNOWN, LicenseName: UNKNOWN, LicenseURL: UNKNOWN},
+	}
+	for _, test := range []struct {
+		desc    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			desc: "CycloneDX",
+			input: `{
+  "bomFormat": "CycloneDX",
+  "specVersion": "1.4",
+  "metadata": {"component": {"name": "base-image"}},
+  "components": [
+    {"bom-ref": "pkg:deb/debian/libc6@2.31", "type": "library", "name": "libc6"},
+    {"bom-ref": "pkg:golang/github.com/google/go-cmp@v0.5.9", "type": "library", "name": "github.com/google/go-cmp"}
+  ]
+}`,
+			want: `{
+  "bomFormat": "CycloneDX",
+  "components": [
+    {"bom-ref": "pkg:deb/debian/libc6@2.31", "name": "libc6", "type": "library"},
+    {"bom-ref": "pkg:golang/github.com/google/go-cmp@v0.5.9", "name": "github.com/google/go-cmp", "type": "library"},
+    {"bom-ref": "pkg:golang/example.com/app", "name": "example.com/app", "purl": "pkg:golang/example.com/app", "type": "library"}
+  ],
+  "metadata": {"component": {"name": "base-image"}},
+  "serialNumber": "urn:uuid:0b8a4a7e-3b1a-4c8e-9f0e-2c5d6f7a8b9c",
+  "specVersion": "1.4",
+  "version": 1
+}`,
+		},
+		{
+			desc: "SPDX",
+			input: `{
+  "spdxVersion": "SPDX-2.3",
+  "SPDXID": "SPDXRef-DOCUMENT",
+  "documentNamespace": "https://example.com/base-image",
+  "packages": [{"SPDXID": "SPDXRef-Package-libc6", "name": "libc6"}]
+}`,
+			want: `{
+  "SPDXID": "SPDXRef-DOCUMENT",
+  "documentNamespace": "https://example.com/base-image-0b8a4a7e-3b1a-4c8e-9f0e-2c5d6f7a8b9c",
+  "packages": [
+    {"SPDXID": "SPDXRef-Package-libc6", "name": "libc6"},
+    {
+      "SPDXID": "SPDXRef-Package-go-github.com-google-go-cmp-v0.5.9",
+      "name": "github.com/google/go-cmp",
+      "versionInfo": "v0.5.9",
+      "downloadLocation": "NOASSERTION",
+      "filesAnalyzed": false,
+      "licenseConcluded": "BSD-3-Clause",
+      "licenseDeclared": "BSD-3-Clause",
+      "copyrightText": "NOASSERTION",
+      "externalRefs": [{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:golang/github.com/google/go-cmp@v0.5.9"}]
+    },
+    {
+      "SPDXID": "SPDXRef-Package-go-example.com-app",
+      "name": "example.com/app",
+      "downloadLocation": "NOASSERTION",
+      "filesAnalyzed": false,
+      "licenseConcluded": "NOASSERTION",
+      "licenseDeclared": "NOASSERTION",
+      "copyrightText": "NOASSERTION",
+      "externalRefs": [{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:golang/example.com/app"}]
+    }
+  ],
+  "relationships": [
+    {"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": "SPDXRef-Package-go-github.com-google-go-cmp-v0.5.9"},
+    {"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": "SPDXRef-Package-go-example.com-app"}
+  ],
+  "spdxVersion": "SPDX-2.3"
+}`,
+		},
+		{
+			desc: "SPDX describing a package",
+			input: `{
+  "spdxVersion": "SPDX-2.3",
+  "SPDXID": "SPDXRef-DOCUMENT",
+  "documentNamespace": "https://example.com/base-image-11111111-2222-4333-8444-555555555555",
+  "packages": [
+    {"SPDXID": "SPDXRef-Package-image", "name": "image"},
+    {"SPDXID": "SPDXRef-Package-go-github.com-google-go-cmp-v0.5.9", "name": "github.com/google/go-cmp"}
+  ],
+  "relationships": [{"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": "SPDXRef-Package-image"}]
+}`,
+			want: `{
+  "SPDXID": "SPDXRef-DOCUMENT",
+  "documentNamespace": "https://example.com/base-image-0b8a4a7e-3b1a-4c8e-9f0e-2c5d6f7a8b9c",
+  "packages": [
+    {"SPDXID": "SPDXRef-Package-image", "name": "image"},
+    {"SPDXID": "SPDXRef-Package-go-github.com-google-go-cmp-v0.5.9", "name": "github.com/google/go-cmp"},
+    {
+      "SPDXID": "SPDXRef-Package-go-example.com-app",
+      "name": "example.com/app",
+      "downloadLocation": "NOASSERTION",
+      "filesAnalyzed": false,
+      "licenseConcluded": "NOASSERTION",
+      "licenseDeclared": "NOASSERTION",
+      "copyrightText": "NOASSERTION",
+      "externalRefs": [{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:golang/example.com/app"}]
+    }
+  ],
+  "relationships": [
+    {"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": "SPDXRef-Package-image"},
+    {"spdxElementId": "SPDXRef-Package-image", "relationshipType": "CONTAINS", "relatedSpdxElement": "SPDXRef-Package-go-example.com-app"}
+  ],
+  "spdxVersion": "SPDX-2.3"
+}`,
+		},
+		{
+			desc:    "Unsupported document",
+			input:   `{"foo": "bar"}`,
+			wantErr: true,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sbom.json")
+			if err := os.WriteFile(path, []byte(test.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var got strings.Builder
+			err := mergeSBOM(&got, path, libs)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("mergeSBOM() = %v, want err? %t", err, test.wantErr)
+			} else if gotErr {
+				return
+			}
+			// Compare the documents as JSON values, ignoring formatting.
+			var gotDoc, wantDoc interface{}
+			if err := json.Unmarshal([]byte(got.String()), &gotDoc); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.want), &wantDoc); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(wantDoc, gotDoc); diff != "" {
+				t.Errorf("mergeSBOM() diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSBOMSchema(t *testing.T) {
+	stubUUID(t)
+	licenseFile := filepath.Join(t.TempDir(), "LICENSE")
+	if err := os.WriteFile(licenseFile, []byte("Facebook license text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	libs := []libraryData{
+		{Name: "github.com/google/go-cmp", Library: "github.com/google/go-cmp", Module: "github.com/google/go-cmp", Version: "v0.5.9", LicenseName: "MIT", LicenseURL: "https://github.com/google/go-cmp/blob/v0.5.9/LICENSE"},
+		{Name: "github.com/facebook/lib", Library: "github.com/facebook/lib", Module: "github.com/facebook/lib", Version: "v1.0.0", LicenseName: "Facebook-2-Clause", LicenseURL: UNKNOWN, licenseFile: licenseFile},
+		{Name: "example.com/app", Library: "example.com/app", Module: "example.com/app", Version: UNKNOWN, LicenseName: UNKNOWN, LicenseURL: UNKNOWN},
+	}
+	cycloneDX := compileSchema(t, "bom-1.4.schema.json", "spdx.schema.json", "jsf-0.82.schema.json")
+	spdx := compileSchema(t, "spdx-schema-2.3.json")
+
+	for _, test := range []struct {
+		desc   string
+		schema *jsonschema.Schema
+		// input is the SBOM to merge libs into, or empty to report them.
+		input string
+	}{
+		{
+			desc:   "CycloneDX report",
+			schema: cycloneDX,
+		},
+		{
+			desc:   "CycloneDX merged",
+			schema: cycloneDX,
+			input: `{
+  "bomFormat": "CycloneDX",
+  "specVersion": "1.4",
+  "serialNumber": "urn:uuid:11111111-2222-4333-8444-555555555555",
+  "version": 3,
+  "components": [{"bom-ref": "pkg:deb/debian/libc6@2.31", "type": "library", "name": "libc6"}]
+}`,
+		},
+		{
+			desc:   "SPDX merged",
+			schema: spdx,
+			input: `{
+  "spdxVersion": "SPDX-2.3",
+  "dataLicense": "CC0-1.0",
+  "SPDXID": "SPDXRef-DOCUMENT",
+  "name": "base-image",
+  "documentNamespace": "https://example.com/base-image-11111111-2222-4333-8444-555555555555",
+  "creationInfo": {"created": "2022-10-01T00:00:00Z", "creators": ["Tool: example"]},
+  "packages": [{"SPDXID": "SPDXRef-Package-libc6", "name": "libc6", "downloadLocation": "NOASSERTION"}]
+}`,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var got bytes.Buffer
+			if test.input == "" {
+				if err := reportCycloneDX(&got, libs); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				path := filepath.Join(t.TempDir(), "sbom.json")
+				if err := os.WriteFile(path, []byte(test.input), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := mergeSBOM(&got, path, libs); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var doc interface{}
+			if err := json.Unmarshal(got.Bytes(), &doc); err != nil {
+				t.Fatal(err)
+			}
+			if err := test.schema.Validate(doc); err != nil {
+				t.Errorf("SBOM doesn't match the schema: %#v\n%s", err, got.String())
+			}
+		})
+	}
+}
+
+// compileSchema compiles the first of the JSON schemas in testdata/sbom, which may refer to the
+// others by their $id.
+func compileSchema(t *testing.T, files ...string) *jsonschema.Schema {
+	t.Helper()
+	compiler := jsonschema.NewCompiler()
+	var ids []string
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join("testdata", "sbom", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var schema struct {
+			ID string `json:"$id"`
+		}
+		if err := json.Unmarshal(content, &schema); err != nil {
+			t.Fatal(err)
+		}
+		if err := compiler.AddResource(schema.ID, bytes.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, schema.ID)
+	}
+	schema, err := compiler.Compile(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func TestSBOMVendored(t *testing.T) {
+	libs := vendoredTestLibraries(t)
+	var purls []string
+	for _, c := range cycloneDXComponents(libs) {
+		purls = append(purls, c.PURL)
+	}
+	// The vendored library is identified by the module it was vendored from.
+	want := []string{
+		"pkg:golang/example.com/notice@v0.1.0",
+		"pkg:golang/github.com/nwoodmsft/go-licenses/testdata/modules/vendored06",
+	}
+	if diff := cmp.Diff(want, purls); diff != "" {
+		t.Errorf("cycloneDXComponents() purls diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestSBOMPackageGranularity(t *testing.T) {
+	libs := packageData([]libraryData{
+		{Name: "golang.org/x/text", Library: "golang.org/x/text", Module: "golang.org/x/text", Version: "v0.3.8", LicenseName: "BSD-3-Clause", LicenseURL: UNKNOWN, Packages: []string{"golang.org/x/text/language", "golang.org/x/text/cases"}},
+	})
+	// The packages of a library are one component or package, with unique IDs.
+	components := cycloneDXComponents(libs)
+	if len(components) != 1 || components[0].Name != "golang.org/x/text" {
+		t.Errorf("cycloneDXComponents() = %+v, want one golang.org/x/text component", components)
+	}
+	pkgs, _ := spdxPackages(libs)
+	if len(pkgs) != 1 || pkgs[0].Name != "golang.org/x/text" {
+		t.Errorf("spdxPackages() = %+v, want one golang.org/x/text package", pkgs)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// scanCodeOutputFormatVersion is the version of the ScanCode Toolkit JSON output format written by
+// reportScanCode.
+const scanCodeOutputFormatVersion = "3.0.0"
+
+type scanCodeOutput struct {
+	Headers []scanCodeHeader `json:"headers"`
+	Files   []scanCodeFile   `json:"files"`
+}
+
+type scanCodeHeader struct {
+	ToolName            string   `json:"tool_name"`
+	OutputFormatVersion string   `json:"output_format_version"`
+	Errors              []string `json:"errors"`
+	Warnings            []string `json:"warnings"`
+	ExtraData           struct {
+		FilesCount int `json:"files_count"`
+	} `json:"extra_data"`
+}
+
+type scanCodeFile struct {
+	Path                          string                     `json:"path"`
+	Type                          string                     `json:"type"`
+	Name                          string                     `json:"name"`
+	BaseName                      string                     `json:"base_name"`
+	Extension                     string                     `json:"extension"`
+	Size                          int64                      `json:"size"`
+	DetectedLicenseExpression     string                     `json:"detected_license_expression"`
+	DetectedLicenseExpressionSPDX string                     `json:"detected_license_expression_spdx"`
+	LicenseDetections             []scanCodeLicenseDetection `json:"license_detections"`
+	ScanErrors                    []string                   `json:"scan_errors"`
+}
+
+type scanCodeLicenseDetection struct {
+	LicenseExpression     string                 `json:"license_expression"`
+	LicenseExpressionSPDX string                 `json:"license_expression_spdx"`
+	Matches               []scanCodeLicenseMatch `json:"matches"`
+}
+
+type scanCodeLicenseMatch struct {
+	LicenseExpression     string `json:"license_expression"`
+	LicenseExpressionSPDX string `json:"spdx_license_expression"`
+	FromFile              string `json:"from_file"`
+	Matcher               string `json:"matcher"`
+}
+
+// reportScanCode writes libs in the JSON output format of ScanCode Toolkit, with an entry per
+// license file, so that the results can be loaded into ScanCode Workbench. Libraries without a
+// license file are written as directory entries with a scan error.
+func reportScanCode(w io.Writer, libs []libraryData) error {
+	header := scanCodeHeader{
+		ToolName:            "go-licenses",
+		OutputFormatVersion: scanCodeOutputFormatVersion,
+		Errors:              []string{},
+		Warnings:            []string{},
+	}
+	files := []scanCodeFile{}
+	for _, lib := range libs {
+		root := lib.Module
+		if root == "" {
+			root = lib.Library
+		}
+		file := scanCodeFile{
+			Path:              root,
+			Type:              "directory",
+			Name:              path.Base(root),
+			BaseName:          path.Base(root),
+			LicenseDetections: []scanCodeLicenseDetection{},
+			ScanErrors:        []string{},
+		}
+		if lib.licenseFile != "" && lib.LicensePath != "" {
+			info, err := os.Stat(lib.licenseFile)
+			if err != nil {
+				return err
+			}
+			file.Path = path.Join(root, lib.LicensePath)
+			file.Type = "file"
+			file.Name = path.Base(lib.LicensePath)
+			file.Extension = path.Ext(file.Name)
+			file.BaseName = strings.TrimSuffix(file.Name, file.Extension)
+			file.Size = info.Size()
+		}
+		if lib.LicenseName != UNKNOWN {
+			file.DetectedLicenseExpression = strings.ToLower(lib.LicenseName)
+			file.DetectedLicenseExpressionSPDX = lib.LicenseName
+			file.LicenseDetections = append(file.LicenseDetections, scanCodeLicenseDetection{
+				LicenseExpression:     file.DetectedLicenseExpression,
+				LicenseExpressionSPDX: file.DetectedLicenseExpressionSPDX,
+				Matches: []scanCodeLicenseMatch{{
+					LicenseExpression:     file.DetectedLicenseExpression,
+					LicenseExpressionSPDX: file.DetectedLicenseExpressionSPDX,
+					FromFile:              file.Path,
+					Matcher:               "go-licenses",
+				}},
+			})
+		}
+		if lib.Details != "" {
+			file.ScanErrors = append(file.ScanErrors, lib.Details)
+		}
+		if file.Type == "file" {
+			header.ExtraData.FilesCount++
+		}
+		files = append(files, file)
+	}
+	return writeJSON(w, scanCodeOutput{Headers: []scanCodeHeader{header}, Files: files})
+}
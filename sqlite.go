@@ -0,0 +1,182 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// reportFormatSQLite is the --format writing reports to a SQLite database.
+const reportFormatSQLite = "sqlite"
+
+// sqliteSchema creates the tables of SQLite reports. Every report run is added to the existing
+// tables, so that the database holds the history of the reports.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS reports (
+	id INTEGER PRIMARY KEY,
+	created_at TEXT NOT NULL,
+	packages TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS licenses (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	type TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS libraries (
+	id INTEGER PRIMARY KEY,
+	report_id INTEGER NOT NULL REFERENCES reports(id),
+	name TEXT NOT NULL,
+	module TEXT NOT NULL,
+	version TEXT NOT NULL,
+	direct INTEGER NOT NULL,
+	license_id INTEGER NOT NULL REFERENCES licenses(id),
+	license_path TEXT NOT NULL,
+	license_url TEXT NOT NULL,
+	status TEXT NOT NULL,
+	details TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS packages (
+	library_id INTEGER NOT NULL REFERENCES libraries(id),
+	name TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS skips (
+	report_id INTEGER NOT NULL REFERENCES reports(id),
+	name TEXT NOT NULL,
+	version TEXT NOT NULL,
+	license_id INTEGER NOT NULL REFERENCES licenses(id),
+	reason TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS violations (
+	library_id INTEGER NOT NULL REFERENCES libraries(id),
+	rule TEXT NOT NULL
+);
+`
+
+func reportSQLiteStream(io.Writer, []libraryData) error {
+	return errors.New("--format=sqlite requires an --output database file")
+}
+
+// reportSQLite adds a report of libs and skipped to the SQLite database at path, creating it if
+// needed. The database is only replaced once the report is complete.
+//
+// Libraries whose licenses policy disallows are recorded as violations, with the rules check
+// would report them for.
+func reportSQLite(path string, packages []string, policy licensePolicy, libs []libraryData, skipped []skippedLibrary) error {
+	temp, err := createReplacement(path)
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+	defer os.Remove(tempPath)
+	// Start from a copy of the existing database to keep its history.
+	if existing, err := os.Open(path); err == nil {
+		_, err = io.Copy(temp, existing)
+		existing.Close()
+		if err != nil {
+			temp.Close()
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	db, err := sql.Open(sqliteDriver, tempPath)
+	if err != nil {
+		return err
+	}
+	if err := writeSQLite(db, packages, policy, libs, skipped); err != nil {
+		db.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+func writeSQLite(db *sql.DB, packages []string, policy licensePolicy, libs []libraryData, skipped []skippedLibrary) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed.
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	res, err := tx.Exec(`INSERT INTO reports (created_at, packages) VALUES (?, ?)`, reportTime().UTC().Format(time.RFC3339), strings.Join(packages, " "))
+	if err != nil {
+		return err
+	}
+	reportID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	licenseID := func(lib libraryData) (int64, error) {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO licenses (name, type) VALUES (?, ?)`, lib.LicenseName, lib.licenseType.String()); err != nil {
+			return 0, err
+		}
+		var id int64
+		err := tx.QueryRow(`SELECT id FROM licenses WHERE name = ?`, lib.LicenseName).Scan(&id)
+		return id, err
+	}
+
+	for _, lib := range libs {
+		license, err := licenseID(lib)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Exec(`INSERT INTO libraries (report_id, name, module, version, direct, license_id, license_path, license_url, status, details) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			reportID, lib.Name, lib.Module, lib.Version, lib.Direct, license, lib.LicensePath, lib.LicenseURL, lib.Status, lib.Details)
+		if err != nil {
+			return err
+		}
+		libID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for _, pkg := range lib.Packages {
+			if _, err := tx.Exec(`INSERT INTO packages (library_id, name) VALUES (?, ?)`, libID, pkg); err != nil {
+				return err
+			}
+		}
+		for _, v := range policy.violations(lib.Name, lib.Module, lib.Version, lib.LicenseName, lib.licenseType) {
+			if _, err := tx.Exec(`INSERT INTO violations (library_id, rule) VALUES (?, ?)`, libID, v.Rule); err != nil {
+				return err
+			}
+		}
+	}
+	for _, s := range skipped {
+		license, err := licenseID(s.lib)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO skips (report_id, name, version, license_id, reason) VALUES (?, ?, ?, ?, ?)`, reportID, s.lib.Name, s.lib.Version, license, s.reason); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
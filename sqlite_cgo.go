@@ -0,0 +1,26 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	// Registers the sqlite3 database/sql driver, which requires cgo.
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is the name of the database/sql driver writing SQLite databases.
+const sqliteDriver = "sqlite3"
@@ -0,0 +1,185 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nwoodmsft/go-licenses/licenses"
+)
+
+func TestReportSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "licenses.db")
+	libs := []libraryData{
+		{Name: "example.com/a", Module: "example.com/a", Version: "v1.0.0", LicenseName: "MIT", licenseType: licenses.Notice, Status: statusOK, Packages: []string{"example.com/a", "example.com/a/b"}},
+		{Name: "example.com/c", Module: "example.com/c", Version: "v0.1.0", LicenseName: UNKNOWN, licenseType: licenses.Unknown, Status: statusUnresolved, Details: "cannot find a license file"},
+	}
+	skipped := []skippedLibrary{
+		{libraryData{Name: "example.com/d", Version: "v2.0.0", LicenseName: "MIT", licenseType: licenses.Notice}, "license matched by --exclude_license"},
+	}
+	policy, err := newLicensePolicy(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A second report is added to the existing database.
+	for i := 0; i < 2; i++ {
+		if err := reportSQLite(path, []string{"./..."}, policy, libs, skipped); err != nil {
+			t.Fatalf("reportSQLite() = %v, want nil", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, test := range []struct {
+		query string
+		want  [][]string
+	}{
+		{
+			query: `SELECT id, packages FROM reports ORDER BY id`,
+			want:  [][]string{{"1", "./..."}, {"2", "./..."}},
+		},
+		{
+			query: `SELECT name, type FROM licenses ORDER BY name`,
+			want:  [][]string{{"MIT", "notice"}, {"Unknown", "unknown"}},
+		},
+		{
+			query: `SELECT l.name, l.version, lic.name, l.status FROM libraries l JOIN licenses lic ON l.license_id = lic.id WHERE l.report_id = 2 ORDER BY l.name`,
+			want:  [][]string{{"example.com/a", "v1.0.0", "MIT", "ok"}, {"example.com/c", "v0.1.0", "Unknown", "unresolved"}},
+		},
+		{
+			query: `SELECT p.name FROM packages p JOIN libraries l ON p.library_id = l.id WHERE l.report_id = 1 ORDER BY p.name`,
+			want:  [][]string{{"example.com/a"}, {"example.com/a/b"}},
+		},
+		{
+			query: `SELECT s.report_id, s.name, s.reason FROM skips s ORDER BY s.report_id`,
+			want:  [][]string{{"1", "example.com/d", "license matched by --exclude_license"}, {"2", "example.com/d", "license matched by --exclude_license"}},
+		},
+		{
+			query: `SELECT l.report_id, l.name, v.rule FROM violations v JOIN libraries l ON v.library_id = l.id ORDER BY l.report_id`,
+			want:  [][]string{{"1", "example.com/c", "disallowed_types=unknown"}, {"2", "example.com/c", "disallowed_types=unknown"}},
+		},
+	} {
+		got := queryStrings(t, db, test.query)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: diff (-want +got):\n%s", test.query, diff)
+		}
+	}
+}
+
+func TestReportSQLiteViolations(t *testing.T) {
+	libs := []libraryData{
+		{Name: "example.com/a", Module: "example.com/a", Version: "v1.0.0", LicenseName: "MIT", licenseType: licenses.Notice},
+		{Name: "example.com/b", Module: "example.com/b", Version: "v1.0.0", LicenseName: "GPL-3.0", licenseType: licenses.Restricted},
+		{Name: "example.com/c", Module: "example.com/c", Version: "v0.1.0", LicenseName: UNKNOWN, licenseType: licenses.Unknown},
+	}
+	for _, test := range []struct {
+		desc            string
+		allowedLicenses []string
+		disallowedTypes []string
+		want            [][]string
+	}{
+		{
+			desc: "Default policy",
+			want: [][]string{{"example.com/c", "disallowed_types=unknown"}},
+		},
+		{
+			desc:            "Disallowed types",
+			disallowedTypes: []string{"restricted"},
+			want:            [][]string{{"example.com/b", "disallowed_types=restricted"}},
+		},
+		{
+			desc:            "Allowed licenses",
+			allowedLicenses: []string{"MIT"},
+			want:            [][]string{{"example.com/b", "allowed_licenses"}, {"example.com/c", "allowed_licenses"}},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			policy, err := newLicensePolicy(test.allowedLicenses, test.disallowedTypes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "licenses.db")
+			if err := reportSQLite(path, []string{"./..."}, policy, libs, nil); err != nil {
+				t.Fatalf("reportSQLite() = %v, want nil", err)
+			}
+			db, err := sql.Open(sqliteDriver, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			got := queryStrings(t, db, `SELECT l.name, v.rule FROM violations v JOIN libraries l ON v.library_id = l.id ORDER BY l.name`)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("violations diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// queryStrings returns the rows of query as strings.
+func queryStrings(t *testing.T, db *sql.DB, query string) [][]string {
+	t.Helper()
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result [][]string
+	for rows.Next() {
+		row := make([]string, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestReportSQLiteMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "licenses.db")
+	if err := reportSQLite(path, []string{"./..."}, licensePolicy{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reportSQLite(path, []string{"./..."}, licensePolicy{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := fileMode(t, path); runtime.GOOS != "windows" && got != 0600 {
+		t.Errorf("mode of updated database = %v, want %v", got, os.FileMode(0600))
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+)
+
+// syftSchemaVersion is the version of the syft JSON schema written by reportSyft, whose licenses
+// are plain strings.
+const syftSchemaVersion = "7.0.0"
+
+type syftDocument struct {
+	Artifacts             []syftArtifact `json:"artifacts"`
+	ArtifactRelationships []interface{}  `json:"artifactRelationships"`
+	Source                syftSource     `json:"source"`
+	Distro                struct{}       `json:"distro"`
+	Descriptor            syftDescriptor `json:"descriptor"`
+	Schema                syftSchema     `json:"schema"`
+}
+
+type syftArtifact struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Version   string         `json:"version"`
+	Type      string         `json:"type"`
+	FoundBy   string         `json:"foundBy"`
+	Locations []syftLocation `json:"locations"`
+	Licenses  []string       `json:"licenses"`
+	Language  string         `json:"language"`
+	CPEs      []string       `json:"cpes"`
+	PURL      string         `json:"purl"`
+}
+
+type syftLocation struct {
+	Path string `json:"path"`
+}
+
+type syftSource struct {
+	Type   string `json:"type"`
+	Target string `json:"target"`
+}
+
+type syftDescriptor struct {
+	Name string `json:"name"`
+}
+
+type syftSchema struct {
+	Version string `json:"version"`
+}
+
+// reportSyft writes libs in the JSON format of syft, with a go-module artifact per module. The
+// licenses and license file locations of all libraries of a module are merged.
+func reportSyft(w io.Writer, libs []libraryData) error {
+	artifactsByPURL := make(map[string]*syftArtifact)
+	var purls []string
+	for _, lib := range libs {
+		module := lib.Module
+		if module == "" {
+			module = lib.Library
+		}
+		version := lib.Version
+		if version == UNKNOWN {
+			version = ""
+		}
+		purl := "pkg:golang/" + module
+		if version != "" {
+			purl += "@" + version
+		}
+		a, ok := artifactsByPURL[purl]
+		if !ok {
+			a = &syftArtifact{
+				ID:        fmt.Sprintf("%x", sha256.Sum256([]byte(purl)))[:16],
+				Name:      module,
+				Version:   version,
+				Type:      "go-module",
+				FoundBy:   "go-licenses",
+				Locations: []syftLocation{},
+				Licenses:  []string{},
+				Language:  "go",
+				CPEs:      []string{},
+				PURL:      purl,
+			}
+			artifactsByPURL[purl] = a
+			purls = append(purls, purl)
+		}
+		a.Licenses = appendLicense(a.Licenses, lib.LicenseName)
+		if lib.LicensePath != "" {
+			a.Locations = append(a.Locations, syftLocation{Path: path.Join(module, lib.LicensePath)})
+		}
+	}
+	sort.Strings(purls)
+
+	doc := syftDocument{
+		Artifacts:             []syftArtifact{},
+		ArtifactRelationships: []interface{}{},
+		Source:                syftSource{Type: "directory", Target: "."},
+		Descriptor:            syftDescriptor{Name: "go-licenses"},
+		Schema:                syftSchema{Version: syftSchemaVersion},
+	}
+	for _, purl := range purls {
+		doc.Artifacts = append(doc.Artifacts, *artifactsByPURL[purl])
+	}
+	return writeJSON(w, doc)
+}
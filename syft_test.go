@@ -0,0 +1,107 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestReportSyft(t *testing.T) {
+	libs := []libraryData{
+		{Library: "golang.org/x/text", Module: "golang.org/x/text", Version: "v0.3.8", LicenseName: "BSD-3-Clause", LicensePath: "LICENSE"},
+		{Library: "golang.org/x/text/internal/foo", Module: "golang.org/x/text", Version: "v0.3.8", LicenseName: "MIT", LicensePath: "internal/foo/LICENSE"},
+		{Library: "example.com/unlicensed", Module: "example.com/unlicensed", Version: UNKNOWN, LicenseName: UNKNOWN},
+	}
+	var out strings.Builder
+	if err := reportSyft(&out, libs); err != nil {
+		t.Fatalf("reportSyft() = %v, want nil", err)
+	}
+	var got syftDocument
+	if err := json.Unmarshal([]byte(out.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []syftArtifact{
+		{
+			Name:      "example.com/unlicensed",
+			Type:      "go-module",
+			FoundBy:   "go-licenses",
+			Locations: []syftLocation{},
+			Licenses:  []string{},
+			Language:  "go",
+			CPEs:      []string{},
+			PURL:      "pkg:golang/example.com/unlicensed",
+		},
+		{
+			Name:    "golang.org/x/text",
+			Version: "v0.3.8",
+			Type:    "go-module",
+			FoundBy: "go-licenses",
+			Locations: []syftLocation{
+				{Path: "golang.org/x/text/LICENSE"},
+				{Path: "golang.org/x/text/internal/foo/LICENSE"},
+			},
+			Licenses: []string{"BSD-3-Clause", "MIT"},
+			Language: "go",
+			CPEs:     []string{},
+			PURL:     "pkg:golang/golang.org/x/text@v0.3.8",
+		},
+	}
+	if diff := cmp.Diff(want, got.Artifacts, cmpopts.IgnoreFields(syftArtifact{}, "ID")); diff != "" {
+		t.Errorf("reportSyft() artifacts diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestReportSyftVendored(t *testing.T) {
+	var out strings.Builder
+	if err := reportSyft(&out, vendoredTestLibraries(t)); err != nil {
+		t.Fatalf("reportSyft() = %v, want nil", err)
+	}
+	var got syftDocument
+	if err := json.Unmarshal([]byte(out.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	// The vendored library is an artifact of its own, not part of the main module's.
+	want := []syftArtifact{
+		{
+			Name:      "example.com/notice",
+			Version:   "v0.1.0",
+			Type:      "go-module",
+			FoundBy:   "go-licenses",
+			Locations: []syftLocation{{Path: "example.com/notice/LICENSE"}},
+			Licenses:  []string{"MIT"},
+			Language:  "go",
+			CPEs:      []string{},
+			PURL:      "pkg:golang/example.com/notice@v0.1.0",
+		},
+		{
+			Name:      "github.com/nwoodmsft/go-licenses/testdata/modules/vendored06",
+			Type:      "go-module",
+			FoundBy:   "go-licenses",
+			Locations: []syftLocation{{Path: "github.com/nwoodmsft/go-licenses/testdata/modules/vendored06/LICENSE"}},
+			Licenses:  []string{"MIT"},
+			Language:  "go",
+			CPEs:      []string{},
+			PURL:      "pkg:golang/github.com/nwoodmsft/go-licenses/testdata/modules/vendored06",
+		},
+	}
+	if diff := cmp.Diff(want, got.Artifacts, cmpopts.IgnoreFields(syftArtifact{}, "ID")); diff != "" {
+		t.Errorf("reportSyft() artifacts diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,15 @@
+module github.com/nwoodmsft/go-licenses/testdata/modules/save05
+
+go 1.15
+
+require (
+	example.com/a/b_c v0.0.0
+	example.com/a_b/c v0.0.0
+	example.com/notice v0.0.0
+)
+
+replace (
+	example.com/a/b_c => ./third_party/a/b_c
+	example.com/a_b/c => ./third_party/a_b/c
+	example.com/notice => ./third_party/notice
+)
@@ -0,0 +1,3 @@
+module example.com/a/b_c
+
+go 1.15
@@ -0,0 +1,3 @@
+module example.com/a_b/c
+
+go 1.15
@@ -0,0 +1,5 @@
+module github.com/nwoodmsft/go-licenses/testdata/modules/vendored06
+
+go 1.15
+
+require example.com/notice v0.1.0